@@ -0,0 +1,548 @@
+package azamat
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lann/builder"
+)
+
+// Audit actions
+const (
+	AuditInsert = "insert"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+
+	// AuditSoftDelete is a delete on a table with a SoftDeleteColumn. The row is still
+	// there, so its entry has the columns that changed, like an update
+	AuditSoftDelete = "soft_delete"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of the context that carries the given actor (eg: the ID of
+// the user making a request). Changes to audited tables that are run with this
+// context are attributed to the actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by the context, if any
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+// AuditLog records the changes that are made to audited tables. A Table is audited
+// when its Audit field is set. Only statements that are run with Run or RunContext
+// are recorded. When they are run on a *sqlx.DB, the statement and its audit entries
+// are written in a transaction.
+type AuditLog struct {
+	// TableName is the name of the table that holds the entries. Defaults to
+	// "audit_log"
+	TableName string
+
+	// Postgres works like it does for Table
+	Postgres bool
+
+	// Clock works like it does for Table
+	Clock func() time.Time
+}
+
+// AuditEntry is a change to a single row
+type AuditEntry struct {
+	ID        int64
+	TableName string `db:"table_name"`
+	RowID     string `db:"row_id"`
+	Action    string
+	Actor     string
+	Changes   []byte    // JSON encoded, see Diff
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Change is the value of a column before and after a change. Before is nil for
+// inserts and After is nil for deletes
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff returns the columns that were changed, keyed by column name
+func (e AuditEntry) Diff() (map[string]Change, error) {
+	var diff map[string]Change
+	err := json.Unmarshal(e.Changes, &diff)
+	return diff, err
+}
+
+// Table returns the definition of the audit log table
+func (a AuditLog) Table() Table[AuditEntry] {
+	name := a.TableName
+	if name == "" {
+		name = "audit_log"
+	}
+
+	schema := managedSchema(
+		ColumnDef{Name: "table_name", Type: TypeText},
+		ColumnDef{Name: "row_id", Type: TypeText},
+		ColumnDef{Name: "action", Type: TypeText},
+		ColumnDef{Name: "actor", Type: TypeText},
+		ColumnDef{Name: "changes", Type: TypeText},
+		ColumnDef{Name: "created_at", Type: TypeTimestamp},
+	)
+
+	return Table[AuditEntry]{
+		Name:            name,
+		Columns:         schema.columnNames(),
+		Schema:          schema,
+		Postgres:        a.Postgres,
+		CreatedAtColumn: "created_at",
+		Clock:           a.Clock,
+	}
+}
+
+// CreateTable creates the audit log table, if it doesn't already exist
+func (a AuditLog) CreateTable(db *sqlx.DB) error {
+	return a.Table().CreateIfNotExists(db)
+}
+
+// History returns the entries for a row, oldest first
+func (a AuditLog) History(
+	runner Runner, table string, rowID any,
+) ([]AuditEntry, error) {
+	return a.Table().
+		Select().
+		Where(sq.Eq{"table_name": table, "row_id": fmt.Sprint(rowID)}).
+		OrderBy("id").
+		All(runner)
+}
+
+func (a AuditLog) record(
+	ctx context.Context,
+	runner Runner,
+	table string,
+	rowID any,
+	action string,
+	changes map[string]Change,
+) error {
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	actor, _ := ActorFromContext(ctx)
+
+	_, err = a.Table().
+		Insert().
+		Columns("table_name", "row_id", "action", "actor", "changes").
+		Values(table, fmt.Sprint(rowID), action, actor, string(encoded)).
+		RunContext(ctx, runner)
+	return err
+}
+
+// History returns the audit entries for the row with the given ID, oldest first. The
+// table has to be audited
+func (t Table[T]) History(runner Runner, id any) ([]AuditEntry, error) {
+	if t.Audit == nil {
+		return nil, fmt.Errorf("%s is not audited", t.Name)
+	}
+	return t.Audit.History(runner, t.Name, id)
+}
+
+func (t Table[T]) auditInsert(
+	ctx context.Context,
+	runner Runner,
+	built sq.InsertBuilder,
+	exec func(Runner) (sql.Result, error),
+) (result sql.Result, err error) {
+	columns := insertColumns(built)
+	values := insertValues(built)
+
+	err = inTransaction(runner, func(runner Runner) error {
+		var ids []any
+		if result, ids, err = t.insertWithIDs(ctx, runner, built, exec); err != nil {
+			return err
+		}
+
+		for i, row := range values {
+			if ids[i] == nil {
+				continue // it wasn't inserted
+			}
+
+			changes := map[string]Change{}
+			for j, column := range columns {
+				changes[column] = Change{After: row[j]}
+			}
+
+			err := t.Audit.record(ctx, runner, t.Name, ids[i], AuditInsert, changes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// insertWithIDs runs an insert and returns the IDs of the rows that were inserted, in
+// the order of their values. If the IDs aren't part of the insert, they come from
+// RETURNING on Postgres (which doesn't support LastInsertId), and otherwise from
+// LastInsertId, inserting one row at a time. Rows that weren't inserted (eg: because
+// of ON CONFLICT DO NOTHING) have a nil ID
+func (t Table[T]) insertWithIDs(
+	ctx context.Context,
+	runner Runner,
+	built sq.InsertBuilder,
+	exec func(Runner) (sql.Result, error),
+) (sql.Result, []any, error) {
+	if insertsSelect(built) {
+		err := fmt.Errorf("inserts into %s are audited, so they can't insert a SELECT", t.Name)
+		return nil, nil, err
+	}
+
+	suffix, err := insertSuffix(built)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := insertColumns(built)
+	values := insertValues(built)
+	ids := make([]any, len(values))
+
+	for i, column := range columns {
+		if column == t.idColumn() {
+			for j, row := range values {
+				ids[j] = row[i]
+			}
+
+			result, err := exec(runner)
+			return result, ids, err
+		}
+	}
+
+	switch {
+	case t.IsPostgres() && strings.Contains(strings.ToUpper(suffix), "RETURNING"):
+		err := fmt.Errorf(
+			"inserts into %s are audited, so they can't have their own RETURNING", t.Name,
+		)
+		return nil, nil, err
+
+	case t.IsPostgres() && suffix == "":
+		// Without a suffix (eg: ON CONFLICT), every row is inserted, so the IDs that
+		// are returned match the values
+		return t.insertReturning(ctx, runner, built, len(values))
+
+	case !t.IsPostgres() && len(values) <= 1:
+		result, err := exec(runner)
+		if err != nil || len(values) == 0 {
+			return result, ids, err
+		}
+
+		id, inserted, err := insertedID(result)
+		if inserted {
+			ids[0] = id
+		}
+		return result, ids, err
+	}
+
+	// The IDs of a multi-row insert aren't guaranteed to be consecutive, and some of
+	// its rows might not be inserted
+	var result insertResult
+	for i, row := range values {
+		single := builder.Set(built, "Values", [][]interface{}{row}).(sq.InsertBuilder)
+
+		id, inserted, err := t.insertRow(ctx, runner, single)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if inserted {
+			ids[i] = id
+			result.lastID = id
+			result.affected++
+		}
+	}
+	return result, ids, nil
+}
+
+// insertReturning runs an insert on Postgres and returns the IDs of its rows, which
+// there have to be as many of as expected
+func (t Table[T]) insertReturning(
+	ctx context.Context, runner Runner, built sq.InsertBuilder, expected int,
+) (sql.Result, []any, error) {
+	rows, err := built.Suffix("RETURNING " + t.idColumn()).
+		RunWith(withContext(runner)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var result insertResult
+	var ids []any
+	for rows.Next() {
+		if err := rows.Scan(&result.lastID); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, result.lastID)
+		result.affected++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(ids) != expected {
+		err := fmt.Errorf("inserted %d rows, expected %d", len(ids), expected)
+		return nil, nil, err
+	}
+	return result, ids, nil
+}
+
+// insertRow runs an insert of a single row and returns its ID. It is false if the row
+// wasn't inserted
+func (t Table[T]) insertRow(
+	ctx context.Context, runner Runner, single sq.InsertBuilder,
+) (int64, bool, error) {
+	if t.IsPostgres() {
+		var id int64
+		err := single.Suffix("RETURNING " + t.idColumn()).
+			RunWith(withContext(runner)).
+			QueryRowContext(ctx).
+			Scan(&id)
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return id, err == nil, err
+	}
+
+	result, err := single.RunWith(withContext(runner)).ExecContext(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return insertedID(result)
+}
+
+// insertedID returns the ID of the row that a single-row insert inserted. It is false
+// if the row wasn't inserted, in which case LastInsertId isn't the row's
+func insertedID(result sql.Result) (int64, bool, error) {
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return 0, false, err
+	}
+
+	id, err := result.LastInsertId()
+	return id, err == nil, err
+}
+
+// insertResult is the result of an insert whose IDs were found by insertWithIDs. Its
+// LastInsertId works on Postgres too
+type insertResult struct {
+	lastID   int64
+	affected int64
+}
+
+func (r insertResult) LastInsertId() (int64, error) {
+	return r.lastID, nil
+}
+
+func (r insertResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+func (t Table[T]) auditUpdate(
+	ctx context.Context,
+	runner Runner,
+	built sq.UpdateBuilder,
+	exec func(Runner) (sql.Result, error),
+) (result sql.Result, err error) {
+	where, _ := builder.Get(built, "WhereParts")
+	whereParts, _ := where.([]sq.Sqlizer)
+
+	err = inTransaction(runner, func(runner Runner) error {
+		before, err := t.snapshot(ctx, runner, true, whereParts...)
+		if err != nil {
+			return err
+		}
+
+		if result, err = exec(runner); err != nil {
+			return err
+		}
+
+		return t.auditChanges(ctx, runner, before, AuditUpdate)
+	})
+
+	return result, err
+}
+
+// auditChanges records the columns that changed in each of the rows, compared to a
+// snapshot from before they were changed
+func (t Table[T]) auditChanges(
+	ctx context.Context, runner Runner, before []map[string]any, action string,
+) error {
+	// The statement might change the columns that were used to find the rows, so
+	// find them by ID instead
+	ids := make([]any, len(before))
+	for i, row := range before {
+		ids[i] = row[t.idColumn()]
+	}
+
+	after, err := t.snapshot(ctx, runner, false, sq.Eq{t.idColumn(): ids})
+	if err != nil {
+		return err
+	}
+
+	afterByID := map[string]map[string]any{}
+	for _, row := range after {
+		afterByID[fmt.Sprint(row[t.idColumn()])] = row
+	}
+
+	for _, row := range before {
+		id := row[t.idColumn()]
+		changes := map[string]Change{}
+		for column, value := range afterByID[fmt.Sprint(id)] {
+			if !reflect.DeepEqual(row[column], value) {
+				changes[column] = Change{Before: row[column], After: value}
+			}
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		if err := t.Audit.record(ctx, runner, t.Name, id, action, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t Table[T]) auditDelete(
+	ctx context.Context,
+	runner Runner,
+	built sq.DeleteBuilder,
+	exec func(Runner) (sql.Result, error),
+) (result sql.Result, err error) {
+	where, _ := builder.Get(built, "WhereParts")
+	whereParts, _ := where.([]sq.Sqlizer)
+
+	err = inTransaction(runner, func(runner Runner) error {
+		before, err := t.snapshot(ctx, runner, true, whereParts...)
+		if err != nil {
+			return err
+		}
+
+		if result, err = exec(runner); err != nil {
+			return err
+		}
+
+		for _, row := range before {
+			changes := map[string]Change{}
+			for column, value := range row {
+				changes[column] = Change{Before: value}
+			}
+
+			id := row[t.idColumn()]
+			err := t.Audit.record(ctx, runner, t.Name, id, AuditDelete, changes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// auditSoftDelete records a soft delete. The rows are found with the same predicate
+// that the soft delete runs with, so rows that were already deleted aren't recorded
+func (t Table[T]) auditSoftDelete(
+	ctx context.Context,
+	runner Runner,
+	built sq.DeleteBuilder,
+	exec func(Runner) (sql.Result, error),
+) (result sql.Result, err error) {
+	where, _ := builder.Get(built, "WhereParts")
+	whereParts, _ := where.([]sq.Sqlizer)
+	whereParts = append(whereParts, sq.Eq{t.softDeleteColumn(): nil})
+
+	err = inTransaction(runner, func(runner Runner) error {
+		before, err := t.snapshot(ctx, runner, true, whereParts...)
+		if err != nil {
+			return err
+		}
+
+		if result, err = exec(runner); err != nil {
+			return err
+		}
+
+		return t.auditChanges(ctx, runner, before, AuditSoftDelete)
+	})
+
+	return result, err
+}
+
+// snapshot reads the current values of the rows that match the given predicates. With
+// lock, the rows are locked FOR UPDATE until the end of the transaction, so they can't
+// change between the snapshot and the statement that writes them
+func (t Table[T]) snapshot(
+	ctx context.Context, runner Runner, lock bool, where ...sq.Sqlizer,
+) ([]map[string]any, error) {
+	columns := t.Columns
+	if !contains(columns, t.idColumn()) {
+		columns = append([]string{t.idColumn()}, columns...)
+	}
+	if t.SoftDeleteColumn != "" && !contains(columns, t.SoftDeleteColumn) {
+		columns = append(columns[:len(columns):len(columns)], t.SoftDeleteColumn)
+	}
+
+	query := sq.Select(columns...).From(t.Name)
+	if t.IsPostgres() {
+		query = psql.Select(columns...).From(t.Name)
+	}
+
+	if len(where) > 0 {
+		query = query.Where(sq.And(where))
+	}
+
+	// SQLite doesn't have row locks, but only allows one transaction to write at a time
+	if dialect := DialectOf(runner); lock && dialect != DialectSQLite && dialect != "" {
+		query = query.Suffix("FOR UPDATE")
+	}
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := withContext(runner).QueryxContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshot []map[string]any
+	for rows.Next() {
+		row := map[string]any{}
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+
+		// Some drivers return text as []byte, which would be JSON encoded as base64
+		for column, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[column] = string(b)
+			}
+		}
+
+		snapshot = append(snapshot, row)
+	}
+
+	return snapshot, rows.Err()
+}
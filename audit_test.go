@@ -0,0 +1,282 @@
+package azamat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableAudit(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+	}
+
+	auditLog := &AuditLog{}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "completed"},
+		Audit:   auditLog,
+	}
+
+	require.NoError(t, auditLog.CreateTable(db))
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		completed BOOLEAN NOT NULL
+	)`)
+
+	ctx := WithActor(context.Background(), "borat")
+
+	// When inserting multiple entries...
+	insert := TodoTable.
+		Insert().
+		Columns("title", "completed").
+		Values("assist Borat", false).
+		Values("find Pamela", false)
+
+	_, err := insert.RunContext(ctx, db)
+	require.NoError(t, err)
+
+	// When updating an entry...
+	update := TodoTable.Update().Set("completed", true).Where("id = ?", 2)
+	_, err = update.RunContext(ctx, db)
+	require.NoError(t, err)
+
+	// When an update doesn't change anything...
+	_, err = update.RunContext(ctx, db)
+	require.NoError(t, err)
+
+	// When deleting an entry (as part of a transaction, without an actor)...
+	err = CommitTransaction(db, func(tx *sqlx.Tx) error {
+		_, err := TodoTable.Delete().Where("id = ?", 2).Run(tx)
+		return err
+	})
+	require.NoError(t, err)
+
+	// Make sure the history of each entry was recorded
+	history, err := TodoTable.History(db, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, AuditInsert, history[0].Action)
+	assert.Equal(t, "borat", history[0].Actor)
+
+	history, err = TodoTable.History(db, 2)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	assert.Equal(t, AuditInsert, history[0].Action)
+	diff, err := history[0].Diff()
+	require.NoError(t, err)
+	assert.Equal(t, Change{After: "find Pamela"}, diff["title"])
+
+	assert.Equal(t, AuditUpdate, history[1].Action)
+	assert.Equal(t, "borat", history[1].Actor)
+	diff, err = history[1].Diff()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Change{
+		"completed": {Before: false, After: true},
+	}, diff)
+
+	assert.Equal(t, AuditDelete, history[2].Action)
+	assert.Equal(t, "", history[2].Actor)
+	diff, err = history[2].Diff()
+	require.NoError(t, err)
+	assert.Equal(t, Change{Before: "find Pamela"}, diff["title"])
+
+	// When the table isn't audited...
+	TodoTable.Audit = nil
+	_, err = TodoTable.History(db, 1)
+	require.Error(t, err)
+}
+
+func TestTableAuditSoftDelete(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	now := time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC)
+	auditLog := &AuditLog{}
+
+	TodoTable := Table[Todo]{
+		Name:             "todos",
+		Columns:          []string{"id", "title"},
+		SoftDeleteColumn: "deleted_at",
+		Clock:            func() time.Time { return now },
+		Audit:            auditLog,
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			deleted_at DATETIME
+		`,
+	}
+
+	require.NoError(t, auditLog.CreateTable(db))
+	require.NoError(t, TodoTable.Create(db))
+
+	_, err := TodoTable.Insert().Columns("title").Values("assist Borat").Run(db)
+	require.NoError(t, err)
+
+	// When soft deleting the same row more than once...
+	for i := 0; i < 3; i++ {
+		_, err = TodoTable.Delete().Where("id = ?", 1).Run(db)
+		require.NoError(t, err)
+	}
+
+	// Make sure only the delete that changed the row was recorded
+	history, err := TodoTable.History(db, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, AuditSoftDelete, history[1].Action)
+
+	diff, err := history[1].Diff()
+	require.NoError(t, err)
+	require.Len(t, diff, 1)
+	assert.Nil(t, diff["deleted_at"].Before)
+	assert.NotNil(t, diff["deleted_at"].After)
+}
+
+func TestTableAuditInsertVariants(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	auditLog := &AuditLog{}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title"},
+		Audit:   auditLog,
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL UNIQUE
+		`,
+	}
+
+	require.NoError(t, auditLog.CreateTable(db))
+	require.NoError(t, TodoTable.Create(db))
+	db.MustExec(`INSERT INTO todos (title) VALUES ('wrestle')`)
+
+	// When some of the rows aren't inserted, only the others are audited
+	result, err := TodoTable.Insert().
+		Columns("title").
+		Values("wrestle").
+		Values("wed").
+		Suffix("ON CONFLICT DO NOTHING").
+		Run(db)
+	require.NoError(t, err)
+
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	history, err := TodoTable.History(db, 1)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	wed, err := TodoTable.Select().Where("title = ?", "wed").Only(db)
+	require.NoError(t, err)
+
+	history, err = TodoTable.History(db, wed.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+
+	// When a single row isn't inserted, it isn't audited as the last inserted row
+	_, err = TodoTable.Insert().
+		Columns("title").
+		Values("wed").
+		Suffix("ON CONFLICT DO NOTHING").
+		Run(db)
+	require.NoError(t, err)
+
+	history, err = TodoTable.History(db, wed.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+
+	// When inserting a SELECT, which can't be audited
+	_, err = TodoTable.Insert().
+		Columns("title").
+		Select(sq.Select("title || '!'").From("todos")).
+		Run(db)
+	require.EqualError(t, err, "inserts into todos are audited, so they can't insert a SELECT")
+
+	// When a Postgres insert has its own RETURNING
+	PostgresTodos := TodoTable
+	PostgresTodos.Postgres = true
+	_, err = PostgresTodos.Insert().
+		Columns("title").
+		Values("fuel van").
+		Suffix("RETURNING id").
+		Run(db)
+	require.EqualError(
+		t, err, "inserts into todos are audited, so they can't have their own RETURNING",
+	)
+}
+
+// lockingRunner is a Runner for a db that has row locks, which records its queries
+// instead of running them
+type lockingRunner struct {
+	Runner
+	queries []string
+}
+
+func (r *lockingRunner) DriverName() string {
+	return "mysql"
+}
+
+func (r *lockingRunner) Queryx(query string, args ...any) (*sqlx.Rows, error) {
+	r.queries = append(r.queries, query)
+	return nil, errors.New("not running it")
+}
+
+func TestTableAuditLocksSnapshot(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title"},
+		Audit:   &AuditLog{},
+	}
+
+	// When the rows are snapshotted before they are written, they are locked
+	for _, run := range []func(Runner) error{
+		func(runner Runner) error {
+			_, err := TodoTable.Update().Set("title", "wed").Where("id = ?", 1).Run(runner)
+			return err
+		},
+		func(runner Runner) error {
+			_, err := TodoTable.Delete().Where("id = ?", 1).Run(runner)
+			return err
+		},
+	} {
+		runner := &lockingRunner{Runner: db}
+		require.Error(t, run(runner))
+		require.Len(t, runner.queries, 1)
+		require.True(t, strings.HasSuffix(runner.queries[0], "FOR UPDATE"), runner.queries[0])
+	}
+}
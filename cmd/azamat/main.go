@@ -0,0 +1,202 @@
+// Command azamat generates azamat Table definitions from an existing database, and
+// typed Columns for existing Table definitions.
+//
+// Usage:
+//
+//	azamat gen -dsn app.db -package models -o models/tables.go
+//	azamat gen -dsn postgres://localhost/app -tables users,todos
+//	azamat cols -o azamat_cols.go ./models
+//
+// cols is meant to be run with go generate:
+//
+//	//go:generate go run github.com/NickDubelman/azamat/cmd/azamat cols
+//
+// The command is built with the SQLite (sqlite3), Postgres (postgres), and MySQL
+// (mysql) drivers. The driver is picked from the DSN, unless -driver is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NickDubelman/azamat"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usage = `usage:
+	azamat gen -dsn <dsn> [flags]
+	azamat cols [-o file] [dir]
+`
+
+const genUsage = `usage: azamat gen -dsn <dsn> [flags]
+
+Introspects a database and generates Go row structs and azamat Table definitions
+for its tables.
+
+Flags:
+`
+
+const colsUsage = `usage: azamat cols [-o file] [dir]
+
+Reads the azamat Table definitions of the Go package in dir (default: the current
+directory) and generates typed Columns for them.
+
+Flags:
+`
+
+// typeFlags collects -type flags, eg: -type uuid=github.com/google/uuid.UUID
+type typeFlags map[string]string
+
+func (t typeFlags) String() string {
+	return fmt.Sprint(map[string]string(t))
+}
+
+func (t typeFlags) Set(value string) error {
+	columnType, goType, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("%q should look like <column type>=<go type>", value)
+	}
+
+	t[columnType] = goType
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "gen":
+		err = gen(os.Args[2:])
+	case "cols":
+		err = cols(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func gen(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), genUsage)
+		flags.PrintDefaults()
+	}
+
+	types := typeFlags{}
+	driver := flags.String("driver", "", "database driver: sqlite3, postgres, or "+
+		"mysql (default: picked from the DSN)")
+	dsn := flags.String("dsn", "", "data source name (required)")
+	pkg := flags.String("package", "models", "name of the generated package")
+	tables := flags.String("tables", "", "comma separated tables (default: all)")
+	nullable := flags.String("nullable", azamat.NullablePointer, "nullable columns "+
+		"are pointers ("+azamat.NullablePointer+") or sql.Null types ("+
+		azamat.NullableSQL+")")
+	singular := flags.Bool("singular", true, "make struct names singular")
+	out := flags.String("o", "", "output file (default: stdout)")
+	flags.Var(types, "type", "map a column type to a Go type, eg: "+
+		"uuid=github.com/google/uuid.UUID (repeatable)")
+
+	flags.Parse(args)
+
+	if *dsn == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *driver == "" {
+		*driver = driverFromDSN(*dsn)
+	}
+
+	db, err := azamat.Connect(*driver, *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	config := azamat.GenConfig{
+		Package:  *pkg,
+		Types:    types,
+		Nullable: *nullable,
+	}
+
+	if *tables != "" {
+		config.Tables = strings.Split(*tables, ",")
+	}
+
+	if !*singular {
+		config.StructName = azamat.CamelCase
+	}
+
+	src, err := azamat.Generate(db, config)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+// driverFromDSN guesses the driver of a DSN: postgres for URLs and key=value
+// connection strings, mysql for go-sql-driver DSNs (eg: user:pass@tcp(host)/db), and
+// sqlite3 for everything else (ie: file names)
+func driverFromDSN(dsn string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return "postgres"
+	case strings.Contains(dsn, "dbname="), strings.Contains(dsn, "host="):
+		return "postgres"
+	case strings.Contains(dsn, "@tcp("), strings.Contains(dsn, "@unix("),
+		strings.Contains(dsn, "@/"):
+		return "mysql"
+	}
+	return "sqlite3"
+}
+
+func cols(args []string) error {
+	flags := flag.NewFlagSet("cols", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), colsUsage)
+		flags.PrintDefaults()
+	}
+
+	out := flags.String("o", "azamat_cols.go", "output file, relative to dir")
+
+	flags.Parse(args)
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	src, err := azamat.GenerateColumns(dir)
+	if err != nil {
+		return err
+	}
+
+	if !filepath.IsAbs(*out) {
+		*out = filepath.Join(dir, *out)
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
@@ -0,0 +1,167 @@
+package azamat
+
+import (
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Column is a column of a Table[T] whose values have the Go type V. It holds the
+// table-qualified name of the column (eg: "todos.title"), so it can be converted to a
+// string wherever a column name is expected. Columns are usually generated with
+// `azamat cols` rather than written by hand.
+//
+// Its predicates (Eq, In, Gt, etc.) only accept values of type V, so comparing a
+// boolean column to a string doesn't compile:
+//
+//	TodoTable.Select().Where(TodoCols.Completed.Eq(true)).OrderByClause(TodoCols.ID.Desc())
+type Column[T any, V any] string
+
+// String returns the table-qualified name of the column, eg: "todos.title"
+func (c Column[T, V]) String() string {
+	return string(c)
+}
+
+// Name returns the name of the column without its table, eg: "title". This is what
+// Insert's Columns and Update's Set expect
+func (c Column[T, V]) Name() string {
+	if i := strings.LastIndex(string(c), "."); i >= 0 {
+		return string(c)[i+1:]
+	}
+	return string(c)
+}
+
+// Table returns the name of the column's table, eg: "todos"
+func (c Column[T, V]) Table() string {
+	if i := strings.LastIndex(string(c), "."); i >= 0 {
+		return string(c)[:i]
+	}
+	return ""
+}
+
+// Of returns the column qualified with a table alias instead, eg: "managers.name" for
+// a table that was aliased with As("managers")
+func (c Column[T, V]) Of(alias string) Column[T, V] {
+	return Column[T, V](alias + "." + c.Name())
+}
+
+// Eq is "column = value". A nil value (eg: a nil pointer or a nil []byte) becomes
+// "column IS NULL"
+func (c Column[T, V]) Eq(value V) sq.Sqlizer {
+	if isNilSlice(value) {
+		return sq.Eq{string(c): nil}
+	}
+	if isList(value) {
+		// squirrel would turn a slice (eg: []byte) into an IN
+		return sq.Expr(string(c)+" = ?", value)
+	}
+	return sq.Eq{string(c): value}
+}
+
+// NotEq is "column <> value". A nil value becomes "column IS NOT NULL"
+func (c Column[T, V]) NotEq(value V) sq.Sqlizer {
+	if isNilSlice(value) {
+		return sq.NotEq{string(c): nil}
+	}
+	if isList(value) {
+		return sq.Expr(string(c)+" <> ?", value)
+	}
+	return sq.NotEq{string(c): value}
+}
+
+// In is "column IN (values...)". Without values, it matches nothing
+func (c Column[T, V]) In(values ...V) sq.Sqlizer {
+	if len(values) == 0 {
+		return sq.Expr("(1=0)")
+	}
+
+	if !isList(values[0]) {
+		return sq.Eq{string(c): values}
+	}
+
+	// Slice values (eg: []byte) can't go through sq.Eq, since it would flatten them
+	or := make(sq.Or, len(values))
+	for i, value := range values {
+		or[i] = c.Eq(value)
+	}
+	return or
+}
+
+// NotIn is "column NOT IN (values...)". Without values, it matches everything
+func (c Column[T, V]) NotIn(values ...V) sq.Sqlizer {
+	if len(values) == 0 {
+		return sq.Expr("(1=1)")
+	}
+
+	if !isList(values[0]) {
+		return sq.NotEq{string(c): values}
+	}
+
+	and := make(sq.And, len(values))
+	for i, value := range values {
+		and[i] = c.NotEq(value)
+	}
+	return and
+}
+
+// Gt is "column > value"
+func (c Column[T, V]) Gt(value V) sq.Sqlizer {
+	return sq.Gt{string(c): value}
+}
+
+// GtOrEq is "column >= value"
+func (c Column[T, V]) GtOrEq(value V) sq.Sqlizer {
+	return sq.GtOrEq{string(c): value}
+}
+
+// Lt is "column < value"
+func (c Column[T, V]) Lt(value V) sq.Sqlizer {
+	return sq.Lt{string(c): value}
+}
+
+// LtOrEq is "column <= value"
+func (c Column[T, V]) LtOrEq(value V) sq.Sqlizer {
+	return sq.LtOrEq{string(c): value}
+}
+
+// Like is "column LIKE pattern"
+func (c Column[T, V]) Like(pattern string) sq.Sqlizer {
+	return sq.Like{string(c): pattern}
+}
+
+// NotLike is "column NOT LIKE pattern"
+func (c Column[T, V]) NotLike(pattern string) sq.Sqlizer {
+	return sq.NotLike{string(c): pattern}
+}
+
+// IsNull is "column IS NULL"
+func (c Column[T, V]) IsNull() sq.Sqlizer {
+	return sq.Eq{string(c): nil}
+}
+
+// IsNotNull is "column IS NOT NULL"
+func (c Column[T, V]) IsNotNull() sq.Sqlizer {
+	return sq.NotEq{string(c): nil}
+}
+
+// Asc is "column ASC", for OrderByClause
+func (c Column[T, V]) Asc() sq.Sqlizer {
+	return sq.Expr(string(c) + " ASC")
+}
+
+// Desc is "column DESC", for OrderByClause
+func (c Column[T, V]) Desc() sq.Sqlizer {
+	return sq.Expr(string(c) + " DESC")
+}
+
+func isList(value any) bool {
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// isNilSlice reports whether a value is a nil slice, which drivers store as NULL
+func isNilSlice(value any) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Slice && v.IsNil()
+}
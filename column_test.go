@@ -0,0 +1,129 @@
+package azamat
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumn(t *testing.T) {
+	type Todo struct{}
+
+	title := Column[Todo, string]("todos.title")
+	require.Equal(t, "todos.title", title.String())
+	require.Equal(t, "title", title.Name())
+	require.Equal(t, "todos", title.Table())
+	require.Equal(t, "done.title", title.Of("done").String())
+
+	// When the column isn't qualified with its table
+	title = Column[Todo, string]("title")
+	require.Equal(t, "title", title.Name())
+	require.Equal(t, "", title.Table())
+}
+
+func TestColumnPredicates(t *testing.T) {
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+		DueAt     *string `db:"due_at"`
+		Data      []byte
+	}
+
+	cols := struct {
+		ID        Column[Todo, int]
+		Title     Column[Todo, string]
+		Completed Column[Todo, bool]
+		DueAt     Column[Todo, *string]
+		Data      Column[Todo, []byte]
+	}{"todos.id", "todos.title", "todos.completed", "todos.due_at", "todos.data"}
+
+	tests := []struct {
+		pred sq.Sqlizer
+		sql  string
+		args []interface{}
+	}{
+		{cols.Completed.Eq(true), "todos.completed = ?", []interface{}{true}},
+		{cols.Title.NotEq("x"), "todos.title <> ?", []interface{}{"x"}},
+		{cols.ID.In(1, 2), "todos.id IN (?,?)", []interface{}{1, 2}},
+		{cols.ID.In(), "(1=0)", nil},
+		{cols.ID.NotIn(1, 2), "todos.id NOT IN (?,?)", []interface{}{1, 2}},
+		{cols.ID.Gt(1), "todos.id > ?", []interface{}{1}},
+		{cols.ID.GtOrEq(1), "todos.id >= ?", []interface{}{1}},
+		{cols.ID.Lt(1), "todos.id < ?", []interface{}{1}},
+		{cols.ID.LtOrEq(1), "todos.id <= ?", []interface{}{1}},
+		{cols.Title.Like("%milk%"), "todos.title LIKE ?", []interface{}{"%milk%"}},
+		{cols.Title.NotLike("%milk%"), "todos.title NOT LIKE ?", []interface{}{"%milk%"}},
+		{cols.DueAt.IsNull(), "todos.due_at IS NULL", nil},
+		{cols.DueAt.IsNotNull(), "todos.due_at IS NOT NULL", nil},
+		{cols.ID.Asc(), "todos.id ASC", nil},
+		{cols.ID.Desc(), "todos.id DESC", nil},
+
+		// When the value is nil
+		{cols.DueAt.Eq(nil), "todos.due_at IS NULL", nil},
+		{cols.Data.Eq(nil), "todos.data IS NULL", nil},
+		{cols.Data.NotEq(nil), "todos.data IS NOT NULL", nil},
+
+		// When the value is a slice, it isn't turned into an IN
+		{cols.Data.Eq([]byte("a")), "todos.data = ?", []interface{}{[]byte("a")}},
+		{
+			cols.Data.In([]byte("a"), []byte("b")),
+			"(todos.data = ? OR todos.data = ?)",
+			[]interface{}{[]byte("a"), []byte("b")},
+		},
+	}
+
+	for _, test := range tests {
+		sql, args, err := test.pred.ToSql()
+		require.NoError(t, err)
+		require.Equal(t, test.sql, sql)
+		require.Equal(t, test.args, args)
+	}
+}
+
+func TestColumnQuery(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "completed"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			completed BOOLEAN NOT NULL
+		`,
+	}
+
+	TodoCols := struct {
+		ID        Column[Todo, int]
+		Title     Column[Todo, string]
+		Completed Column[Todo, bool]
+	}{"todos.id", "todos.title", "todos.completed"}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	_, err := TodoTable.Insert().
+		Columns(TodoCols.Title.Name(), TodoCols.Completed.Name()).
+		Values("assist Borat", true).
+		Values("find Pamela", false).
+		Values("return to Kazakhstan", true).
+		Run(db)
+	require.NoError(t, err)
+
+	todos, err := TodoTable.Select().
+		Where(TodoCols.Completed.Eq(true)).
+		OrderByClause(TodoCols.ID.Desc()).
+		All(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	require.Equal(t, "return to Kazakhstan", todos[0].Title)
+	require.Equal(t, "assist Borat", todos[1].Title)
+}
@@ -0,0 +1,219 @@
+package azamat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Portable column types. When a Schema is rendered, they are translated to whatever
+// the dialect calls them. Other types are passed through as is
+const (
+	TypeInteger   = "INTEGER"
+	TypeBigInt    = "BIGINT"
+	TypeText      = "TEXT"
+	TypeBoolean   = "BOOLEAN"
+	TypeDouble    = "DOUBLE PRECISION"
+	TypeTimestamp = "TIMESTAMP"
+	TypeBlob      = "BLOB"
+	TypeJSON      = "JSON"
+)
+
+// Varchar returns a VARCHAR type with the given length
+func Varchar(length int) string {
+	return fmt.Sprintf("VARCHAR(%d)", length)
+}
+
+// dialectTypes maps portable types to the types that a dialect uses instead
+var dialectTypes = map[Dialect]map[string]string{
+	DialectSQLite: {
+		// SQLite would give JSON numeric affinity
+		TypeJSON: TypeText,
+	},
+	DialectPostgres: {
+		TypeBlob: "BYTEA",
+	},
+	DialectMySQL: {
+		TypeDouble: "DOUBLE",
+
+		// MySQL's TIMESTAMP only goes up to 2038 and updates itself by default
+		TypeTimestamp: "DATETIME",
+	},
+}
+
+// sqlType returns the column's type in the given dialect
+func (c ColumnDef) sqlType(dialect Dialect) string {
+	typ := c.Type
+	if mapped, ok := dialectTypes[dialect][strings.ToUpper(typ)]; ok {
+		typ = mapped
+	}
+
+	if c.AutoIncrement {
+		switch dialect {
+		case DialectSQLite:
+			// Only an INTEGER PRIMARY KEY can autoincrement
+			return TypeInteger
+		case DialectPostgres:
+			if typeFamily(typ) == "bigint" {
+				return "BIGSERIAL"
+			}
+			return "SERIAL"
+		}
+	}
+
+	return typ
+}
+
+// sql renders the column's definition, without its primary key
+func (c ColumnDef) sql(dialect Dialect) string {
+	s := c.Name
+	if typ := c.sqlType(dialect); typ != "" {
+		s += " " + typ
+	}
+	if !c.Nullable {
+		s += " NOT NULL"
+	}
+	if c.Default != "" {
+		s += " DEFAULT " + c.Default
+	}
+	if c.AutoIncrement && dialect == DialectMySQL {
+		s += " AUTO_INCREMENT"
+	}
+	return s
+}
+
+// CreateTableSQL renders a CREATE TABLE statement for the schema in the given dialect
+func (s Schema) CreateTableSQL(table string, dialect Dialect) string {
+	return s.createTableSQL(table, dialect, false)
+}
+
+func (s Schema) createTableSQL(table string, dialect Dialect, ifNotExists bool) string {
+	var defs []string
+	for _, column := range s.Columns {
+		def := column.sql(dialect)
+
+		// A single column primary key is declared with the column, since that's the
+		// only way SQLite can autoincrement it
+		if len(s.PrimaryKey) == 1 && strings.EqualFold(s.PrimaryKey[0], column.Name) {
+			def += " PRIMARY KEY"
+			if column.AutoIncrement && dialect == DialectSQLite {
+				def += " AUTOINCREMENT"
+			}
+		}
+
+		defs = append(defs, def)
+	}
+
+	if len(s.PrimaryKey) > 1 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", columnList(s.PrimaryKey)))
+	}
+
+	for _, u := range s.Uniques {
+		defs = append(defs, constraintSQL(u.Name, fmt.Sprintf(
+			"UNIQUE (%s)", columnList(u.Columns),
+		)))
+	}
+
+	for _, fk := range s.ForeignKeys {
+		defs = append(defs, constraintSQL(fk.Name, foreignKeySQL(fk)))
+	}
+
+	for _, check := range s.Checks {
+		defs = append(defs, constraintSQL(check.Name, fmt.Sprintf(
+			"CHECK (%s)", check.Expr,
+		)))
+	}
+
+	create := "CREATE TABLE"
+	if ifNotExists {
+		create = "CREATE TABLE IF NOT EXISTS"
+	}
+
+	return fmt.Sprintf("%s %s (%s)", create, table, strings.Join(defs, ", "))
+}
+
+// CreateIndexSQL renders a CREATE INDEX statement for each of the schema's indexes in
+// the given dialect
+func (s Schema) CreateIndexSQL(table string, dialect Dialect) ([]string, error) {
+	return createIndexSQL(table, s.Indexes, dialect, false)
+}
+
+func createIndexSQL(
+	table string, indexes []IndexDef, dialect Dialect, ifNotExists bool,
+) ([]string, error) {
+	statements := make([]string, len(indexes))
+	for i, index := range indexes {
+		statement, err := index.createSQL(table, dialect, ifNotExists)
+		if err != nil {
+			return nil, err
+		}
+		statements[i] = statement
+	}
+	return statements, nil
+}
+
+func (index IndexDef) createSQL(
+	table string, dialect Dialect, ifNotExists bool,
+) (string, error) {
+	if index.Where != "" && dialect == DialectMySQL {
+		return "", fmt.Errorf(
+			"index %s is partial, which MySQL doesn't support", index.Name,
+		)
+	}
+
+	create := "CREATE INDEX"
+	if index.Unique {
+		create = "CREATE UNIQUE INDEX"
+	}
+	if ifNotExists {
+		create += " IF NOT EXISTS"
+	}
+
+	parts := append([]string(nil), index.Columns...)
+	for _, expr := range index.Expressions {
+		parts = append(parts, "("+expr+")")
+	}
+
+	s := fmt.Sprintf("%s %s ON %s (%s)", create, index.Name, table, columnList(parts))
+	if index.Where != "" {
+		s += " WHERE " + index.Where
+	}
+	return s, nil
+}
+
+func constraintSQL(name, sql string) string {
+	if name == "" {
+		return sql
+	}
+	return fmt.Sprintf("CONSTRAINT %s %s", name, sql)
+}
+
+func foreignKeySQL(fk ForeignKeyDef) string {
+	s := fmt.Sprintf(
+		"FOREIGN KEY (%s) REFERENCES %s (%s)",
+		columnList(fk.Columns), fk.RefTable, columnList(fk.RefColumns),
+	)
+	if fk.OnDelete != "" {
+		s += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		s += " ON UPDATE " + fk.OnUpdate
+	}
+	return s
+}
+
+// createFromSchema creates the table from its Schema
+func (t Table[T]) createFromSchema(db *sqlx.DB, ifNotExists bool) error {
+	if err := t.Schema.Validate(); err != nil {
+		return fmt.Errorf("invalid schema for %s: %w", t.Name, err)
+	}
+
+	dialect := DialectOf(db)
+	if dialect == "" {
+		dialect = t.dialect()
+	}
+
+	_, err := db.Exec(t.Schema.createTableSQL(t.Name, dialect, ifNotExists))
+	return err
+}
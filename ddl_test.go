@@ -0,0 +1,147 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var todoSchema = Schema{
+	Columns: []ColumnDef{
+		{Name: "id", Type: TypeBigInt, AutoIncrement: true},
+		{Name: "title", Type: Varchar(255)},
+		{Name: "completed", Type: TypeBoolean, Default: "false"},
+		{Name: "author_id", Type: TypeInteger, Nullable: true},
+		{Name: "created_at", Type: TypeTimestamp},
+		{Name: "data", Type: TypeBlob, Nullable: true},
+	},
+	PrimaryKey: []string{"id"},
+	Uniques: []UniqueDef{
+		{Name: "todos_author_title", Columns: []string{"author_id", "title"}},
+	},
+	ForeignKeys: []ForeignKeyDef{
+		{
+			Columns:    []string{"author_id"},
+			RefTable:   "users",
+			RefColumns: []string{"id"},
+			OnDelete:   "CASCADE",
+		},
+	},
+	Checks:  []CheckDef{{Expr: "title <> ''"}},
+	Indexes: []IndexDef{{Name: "todos_completed", Columns: []string{"completed"}}},
+}
+
+func TestSchemaCreateTableSQL(t *testing.T) {
+	constraints := "CONSTRAINT todos_author_title UNIQUE (author_id, title), " +
+		"FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE, " +
+		"CHECK (title <> '')"
+
+	sqlite := todoSchema.CreateTableSQL("todos", DialectSQLite)
+	assert.Equal(
+		t,
+		"CREATE TABLE todos ("+
+			"id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, "+
+			"title VARCHAR(255) NOT NULL, "+
+			"completed BOOLEAN NOT NULL DEFAULT false, "+
+			"author_id INTEGER, "+
+			"created_at TIMESTAMP NOT NULL, "+
+			"data BLOB, "+
+			constraints+")",
+		sqlite,
+	)
+
+	postgres := todoSchema.CreateTableSQL("todos", DialectPostgres)
+	assert.Equal(
+		t,
+		"CREATE TABLE todos ("+
+			"id BIGSERIAL NOT NULL PRIMARY KEY, "+
+			"title VARCHAR(255) NOT NULL, "+
+			"completed BOOLEAN NOT NULL DEFAULT false, "+
+			"author_id INTEGER, "+
+			"created_at TIMESTAMP NOT NULL, "+
+			"data BYTEA, "+
+			constraints+")",
+		postgres,
+	)
+
+	mysql := todoSchema.CreateTableSQL("todos", DialectMySQL)
+	assert.Equal(
+		t,
+		"CREATE TABLE todos ("+
+			"id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, "+
+			"title VARCHAR(255) NOT NULL, "+
+			"completed BOOLEAN NOT NULL DEFAULT false, "+
+			"author_id INTEGER, "+
+			"created_at DATETIME NOT NULL, "+
+			"data BLOB, "+
+			constraints+")",
+		mysql,
+	)
+
+	// When the primary key has multiple columns...
+	schema := Schema{
+		Columns: []ColumnDef{
+			{Name: "user_id", Type: TypeInteger},
+			{Name: "group_id", Type: TypeInteger},
+		},
+		PrimaryKey: []string{"user_id", "group_id"},
+	}
+
+	assert.Equal(
+		t,
+		"CREATE TABLE memberships (user_id INTEGER NOT NULL, group_id INTEGER NOT NULL, "+
+			"PRIMARY KEY (user_id, group_id))",
+		schema.CreateTableSQL("memberships", DialectSQLite),
+	)
+
+	indexes, err := todoSchema.CreateIndexSQL("todos", DialectSQLite)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CREATE INDEX todos_completed ON todos (completed)"}, indexes)
+}
+
+func TestTableCreateFromSchema(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+		AuthorID  *int `db:"author_id"`
+	}
+
+	schema := todoSchema
+	TodoTable := Table[Todo]{
+		Name:            "todos",
+		Columns:         []string{"id", "title", "completed", "author_id"},
+		Schema:          &schema,
+		CreatedAtColumn: "created_at",
+	}
+
+	db.MustExec("CREATE TABLE users (id INTEGER PRIMARY KEY)")
+	require.NoError(t, TodoTable.Create(db))
+
+	_, err := TodoTable.Insert().Columns("title").Values("assist Borat").Run(db)
+	require.NoError(t, err)
+
+	todo, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, Todo{ID: 1, Title: "assist Borat"}, todo)
+
+	// (the table matches its declared schema)
+	users := Table[struct{ ID int }]{Name: "users", RawSchema: "id INTEGER PRIMARY KEY"}
+	diff, err := Diff(db, TodoTable, users)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+
+	// When the table already exists...
+	require.Error(t, TodoTable.Create(db))
+	require.NoError(t, TodoTable.CreateIfNotExists(db))
+
+	// When the schema is invalid...
+	TodoTable.Name = "todos2"
+	TodoTable.Schema = &Schema{Columns: []ColumnDef{{Name: "id"}}}
+	require.Error(t, TodoTable.Create(db))
+}
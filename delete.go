@@ -1,6 +1,7 @@
 package azamat
 
 import (
+	"context"
 	"database/sql"
 
 	sq "github.com/Masterminds/squirrel"
@@ -8,14 +9,107 @@ import (
 
 type DeleteBuilder struct {
 	sq.DeleteBuilder
+	hooks   []hook[sq.DeleteBuilder]
+	wrapRun wrapRun[sq.DeleteBuilder]
+
+	// asUpdate is set when the delete should be carried out as an update instead
+	// (ie: for tables with soft deletes)
+	asUpdate func(sq.DeleteBuilder) sq.UpdateBuilder
 }
 
 func Delete(from string) DeleteBuilder {
-	return DeleteBuilder{sq.Delete(from)}
+	return DeleteBuilder{DeleteBuilder: sq.Delete(from)}
 }
 
 func (b DeleteBuilder) Run(runner Runner) (sql.Result, error) {
-	return b.RunWith(runner).Exec()
+	return b.RunContext(context.Background(), runner)
+}
+
+// RunContext is like Run but passes the context to the database and to any behavior
+// that the statement's Table adds (eg: tenant isolation)
+func (b DeleteBuilder) RunContext(
+	ctx context.Context, runner Runner,
+) (sql.Result, error) {
+	if b.wrapRun == nil {
+		return b.RunWith(withContext(runner)).ExecContext(ctx)
+	}
+
+	built, err := applyHooks(ctx, b.DeleteBuilder, b.hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := func(runner Runner) (sql.Result, error) {
+		return b.statement(built.RunWith(withContext(runner))).ExecContext(ctx)
+	}
+	return b.wrapRun(ctx, runner, built, exec)
+}
+
+// deleteStatement is what a DeleteBuilder turns into right before it is run. This is
+// usually a squirrel DeleteBuilder, but can be an UpdateBuilder for soft deletes
+type deleteStatement interface {
+	ToSql() (string, []interface{}, error)
+	Exec() (sql.Result, error)
+	Query() (*sql.Rows, error)
+	ExecContext(ctx context.Context) (sql.Result, error)
+	QueryContext(ctx context.Context) (*sql.Rows, error)
+}
+
+func (b DeleteBuilder) build(ctx context.Context) (deleteStatement, error) {
+	built, err := applyHooks(ctx, b.DeleteBuilder, b.hooks)
+	if err != nil {
+		return nil, err
+	}
+	return b.statement(built), nil
+}
+
+// statement returns the statement that actually carries out the delete
+func (b DeleteBuilder) statement(built sq.DeleteBuilder) deleteStatement {
+	if b.asUpdate != nil {
+		return b.asUpdate(built)
+	}
+	return built
+}
+
+// ToSql builds the query into a SQL string and bound args
+func (b DeleteBuilder) ToSql() (string, []interface{}, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return "", nil, err
+	}
+	return built.ToSql()
+}
+
+func (b DeleteBuilder) Exec() (sql.Result, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return built.Exec()
+}
+
+func (b DeleteBuilder) Query() (*sql.Rows, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return built.Query()
+}
+
+func (b DeleteBuilder) ExecContext(ctx context.Context) (sql.Result, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.ExecContext(ctx)
+}
+
+func (b DeleteBuilder) QueryContext(ctx context.Context) (*sql.Rows, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.QueryContext(ctx)
 }
 
 func (b DeleteBuilder) PlaceholderFormat(f sq.PlaceholderFormat) DeleteBuilder {
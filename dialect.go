@@ -0,0 +1,32 @@
+package azamat
+
+// Dialect is a flavor of SQL. Most of azamat works the same across dialects, but some
+// features (like row locking and schema management) need to know which one they are
+// talking to
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+)
+
+// DialectOf returns the dialect of the database that the runner is connected to,
+// based on its driver name. It returns an empty Dialect if the driver is unknown
+func DialectOf(runner any) Dialect {
+	r, ok := runner.(interface{ DriverName() string })
+	if !ok {
+		return ""
+	}
+
+	switch r.DriverName() {
+	case "sqlite3", "sqlite":
+		return DialectSQLite
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return DialectPostgres
+	case "mysql":
+		return DialectMySQL
+	}
+
+	return ""
+}
@@ -0,0 +1,529 @@
+package azamat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeclaredTable is a table whose schema is declared in code, such as a Table
+type DeclaredTable interface {
+	String() string
+	DeclaredSchema() (Schema, error)
+}
+
+// DeclaredSchema returns the table's Schema or, if it doesn't have one, the schema
+// that it declares in its RawSchema. Either way, it includes the table's Indexes
+func (t Table[T]) DeclaredSchema() (Schema, error) {
+	var schema Schema
+	if t.Schema != nil {
+		schema = *t.Schema
+	} else {
+		var err error
+		if schema, err = ParseSchema(t.RawSchema); err != nil {
+			return schema, fmt.Errorf("parsing the schema of %s: %w", t.Name, err)
+		}
+	}
+
+	schema.Indexes = t.indexes()
+	return schema, nil
+}
+
+// SchemaChangeKind is the kind of difference between a declared schema and the db
+type SchemaChangeKind string
+
+const (
+	MissingTable      SchemaChangeKind = "missing table"
+	ExtraTable        SchemaChangeKind = "extra table"
+	MissingColumn     SchemaChangeKind = "missing column"
+	ExtraColumn       SchemaChangeKind = "extra column"
+	ColumnType        SchemaChangeKind = "column type"
+	ColumnNullability SchemaChangeKind = "column nullability"
+	PrimaryKeyChanged SchemaChangeKind = "primary key"
+	MissingIndex      SchemaChangeKind = "missing index"
+	ExtraIndex        SchemaChangeKind = "extra index"
+	IndexChanged      SchemaChangeKind = "index"
+	MissingConstraint SchemaChangeKind = "missing constraint"
+	ExtraConstraint   SchemaChangeKind = "extra constraint"
+)
+
+// SchemaChange is a single difference between a declared schema and the db
+type SchemaChange struct {
+	Kind  SchemaChangeKind
+	Table string
+
+	// Name is the name of the column, index, or constraint, if the change is about one
+	Name string
+
+	// Declared and Actual describe what the code declares and what the db has
+	Declared string
+	Actual   string
+
+	// Statements reconcile the db with the declared schema. They are empty if that
+	// can't be done with ALTER statements (eg: SQLite can't change a column's type
+	// without rebuilding the table)
+	Statements []string
+}
+
+func (c SchemaChange) String() string {
+	s := fmt.Sprintf("%s: %s", c.Table, c.Kind)
+	if c.Name != "" {
+		s += " " + c.Name
+	}
+
+	if c.Declared != "" || c.Actual != "" {
+		s += fmt.Sprintf(" (declared %q, actual %q)", c.Declared, c.Actual)
+	}
+	return s
+}
+
+// SchemaDiff is the set of differences between the declared schemas and the db
+type SchemaDiff struct {
+	Dialect Dialect
+	Changes []SchemaChange
+}
+
+// Empty is true if the db matches the declared schemas
+func (d SchemaDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Statements returns the statements that reconcile the db with the declared schemas.
+// They include destructive statements for extra tables, columns, and indexes, so they
+// should be reviewed before they are run
+func (d SchemaDiff) Statements() (statements []string) {
+	for _, change := range d.Changes {
+		statements = append(statements, change.Statements...)
+	}
+	return
+}
+
+func (d SchemaDiff) String() string {
+	lines := make([]string, len(d.Changes))
+	for i, change := range d.Changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff compares the declared schemas of the given tables with the db that the runner
+// is connected to. Tables that are in the db but weren't given are reported as extra.
+// Column defaults and check constraints aren't compared, since every dialect formats
+// them differently
+func Diff(runner Runner, tables ...DeclaredTable) (SchemaDiff, error) {
+	diff := SchemaDiff{Dialect: DialectOf(runner)}
+
+	names, err := TableNames(runner)
+	if err != nil {
+		return diff, err
+	}
+
+	exists := map[string]bool{}
+	for _, name := range names {
+		exists[strings.ToLower(name)] = true
+	}
+
+	declared := map[string]bool{}
+	for _, table := range tables {
+		name := table.String()
+		declared[strings.ToLower(name)] = true
+
+		schema, err := table.DeclaredSchema()
+		if err != nil {
+			return diff, err
+		}
+
+		if !exists[strings.ToLower(name)] {
+			indexes, err := schema.CreateIndexSQL(name, diff.Dialect)
+			if err != nil {
+				return diff, err
+			}
+
+			statements := []string{schema.CreateTableSQL(name, diff.Dialect)}
+			statements = append(statements, indexes...)
+
+			diff.Changes = append(diff.Changes, SchemaChange{
+				Kind:       MissingTable,
+				Table:      name,
+				Statements: statements,
+			})
+			continue
+		}
+
+		actual, err := Introspect(runner, name)
+		if err != nil {
+			return diff, err
+		}
+
+		diff.Changes = append(diff.Changes, diffTable(diff.Dialect, name, schema, actual)...)
+	}
+
+	for _, name := range names {
+		if declared[strings.ToLower(name)] {
+			continue
+		}
+
+		diff.Changes = append(diff.Changes, SchemaChange{
+			Kind:       ExtraTable,
+			Table:      name,
+			Statements: []string{fmt.Sprintf("DROP TABLE %s", name)},
+		})
+	}
+
+	return diff, nil
+}
+
+func diffTable(
+	dialect Dialect, table string, declared, actual Schema,
+) (changes []SchemaChange) {
+	for _, column := range declared.Columns {
+		existing, ok := actual.Column(column.Name)
+		if !ok {
+			changes = append(changes, SchemaChange{
+				Kind:  MissingColumn,
+				Table: table,
+				Name:  column.Name,
+				Statements: []string{fmt.Sprintf(
+					"ALTER TABLE %s ADD COLUMN %s", table, column.sql(dialect),
+				)},
+			})
+			continue
+		}
+
+		declaredType := column.sqlType(dialect)
+		typeChanged := typeFamily(declaredType) != typeFamily(existing.Type)
+		if typeChanged {
+			var statements []string
+			switch dialect {
+			case DialectPostgres:
+				statements = []string{fmt.Sprintf(
+					"ALTER TABLE %s ALTER COLUMN %s TYPE %s",
+					table, column.Name, declaredType,
+				)}
+			case DialectMySQL:
+				statements = []string{fmt.Sprintf(
+					"ALTER TABLE %s MODIFY COLUMN %s", table, column.sql(dialect),
+				)}
+			}
+
+			changes = append(changes, SchemaChange{
+				Kind:       ColumnType,
+				Table:      table,
+				Name:       column.Name,
+				Declared:   declaredType,
+				Actual:     existing.Type,
+				Statements: statements,
+			})
+		}
+
+		if column.Nullable != existing.Nullable {
+			var statements []string
+			switch {
+			case dialect == DialectPostgres:
+				action := "SET NOT NULL"
+				if column.Nullable {
+					action = "DROP NOT NULL"
+				}
+				statements = []string{fmt.Sprintf(
+					"ALTER TABLE %s ALTER COLUMN %s %s", table, column.Name, action,
+				)}
+
+			// MODIFY COLUMN already changed the nullability along with the type
+			case dialect == DialectMySQL && !typeChanged:
+				statements = []string{fmt.Sprintf(
+					"ALTER TABLE %s MODIFY COLUMN %s", table, column.sql(dialect),
+				)}
+			}
+
+			changes = append(changes, SchemaChange{
+				Kind:       ColumnNullability,
+				Table:      table,
+				Name:       column.Name,
+				Declared:   nullability(column.Nullable),
+				Actual:     nullability(existing.Nullable),
+				Statements: statements,
+			})
+		}
+	}
+
+	for _, column := range actual.Columns {
+		if _, ok := declared.Column(column.Name); !ok {
+			changes = append(changes, SchemaChange{
+				Kind:  ExtraColumn,
+				Table: table,
+				Name:  column.Name,
+				Statements: []string{
+					fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column.Name),
+				},
+			})
+		}
+	}
+
+	if columnList(declared.PrimaryKey) != columnList(actual.PrimaryKey) {
+		changes = append(changes, SchemaChange{
+			Kind:     PrimaryKeyChanged,
+			Table:    table,
+			Declared: columnList(declared.PrimaryKey),
+			Actual:   columnList(actual.PrimaryKey),
+		})
+	}
+
+	changes = append(changes, diffIndexes(dialect, table, declared, actual)...)
+	changes = append(changes, diffConstraints(dialect, table, declared, actual)...)
+	return changes
+}
+
+func diffIndexes(
+	dialect Dialect, table string, declared, actual Schema,
+) (changes []SchemaChange) {
+	actualByName := map[string]IndexDef{}
+	for _, index := range actual.Indexes {
+		actualByName[strings.ToLower(index.Name)] = index
+	}
+
+	declaredByName := map[string]bool{}
+	for _, index := range declared.Indexes {
+		declaredByName[strings.ToLower(index.Name)] = true
+
+		existing, ok := actualByName[strings.ToLower(index.Name)]
+		if !ok {
+			changes = append(changes, SchemaChange{
+				Kind:       MissingIndex,
+				Table:      table,
+				Name:       index.Name,
+				Statements: createIndexStatements(table, index, dialect),
+			})
+			continue
+		}
+
+		// Expression and partial indexes are spelled differently by every dialect, so
+		// they are only compared by name
+		if len(index.Expressions) > 0 || index.Where != "" {
+			continue
+		}
+
+		if indexSignature(index) != indexSignature(existing) {
+			statements := createIndexStatements(table, index, dialect)
+			if len(statements) > 0 {
+				statements = append(
+					[]string{dropIndexSQL(dialect, table, existing.Name)}, statements...,
+				)
+			}
+
+			changes = append(changes, SchemaChange{
+				Kind:       IndexChanged,
+				Table:      table,
+				Name:       index.Name,
+				Declared:   indexSignature(index),
+				Actual:     indexSignature(existing),
+				Statements: statements,
+			})
+		}
+	}
+
+	for _, index := range actual.Indexes {
+		if declaredByName[strings.ToLower(index.Name)] {
+			continue
+		}
+
+		changes = append(changes, SchemaChange{
+			Kind:       ExtraIndex,
+			Table:      table,
+			Name:       index.Name,
+			Statements: []string{dropIndexSQL(dialect, table, index.Name)},
+		})
+	}
+
+	return changes
+}
+
+// diffConstraints compares unique and foreign key constraints. They are matched by
+// what they constrain rather than by name, since SQLite doesn't keep their names
+func diffConstraints(
+	dialect Dialect, table string, declared, actual Schema,
+) (changes []SchemaChange) {
+	type constraint struct {
+		name string
+		sql  string
+		kind string
+	}
+
+	constraints := func(s Schema) (all []constraint) {
+		for _, u := range s.Uniques {
+			all = append(all, constraint{
+				name: u.Name,
+				sql:  fmt.Sprintf("UNIQUE (%s)", columnList(u.Columns)),
+				kind: "UNIQUE",
+			})
+		}
+		for _, fk := range s.ForeignKeys {
+			all = append(all, constraint{
+				name: fk.Name,
+				sql:  foreignKeySQL(fk),
+				kind: "FOREIGN KEY",
+			})
+		}
+		return
+	}
+
+	has := func(all []constraint, c constraint) bool {
+		for _, other := range all {
+			if strings.EqualFold(other.sql, c.sql) {
+				return true
+			}
+		}
+		return false
+	}
+
+	declaredConstraints := constraints(declared)
+	actualConstraints := constraints(actual)
+
+	// SQLite can't add or drop constraints without rebuilding the table
+	alterable := dialect == DialectPostgres || dialect == DialectMySQL
+
+	for _, c := range declaredConstraints {
+		if has(actualConstraints, c) {
+			continue
+		}
+
+		var statements []string
+		if alterable {
+			add := c.sql
+			if c.name != "" {
+				add = fmt.Sprintf("CONSTRAINT %s %s", c.name, c.sql)
+			}
+			statements = []string{fmt.Sprintf("ALTER TABLE %s ADD %s", table, add)}
+		}
+
+		changes = append(changes, SchemaChange{
+			Kind:       MissingConstraint,
+			Table:      table,
+			Name:       c.name,
+			Declared:   c.sql,
+			Statements: statements,
+		})
+	}
+
+	for _, c := range actualConstraints {
+		if has(declaredConstraints, c) {
+			continue
+		}
+
+		var statements []string
+		if alterable && c.name != "" {
+			drop := "CONSTRAINT"
+			if dialect == DialectMySQL && c.kind == "UNIQUE" {
+				drop = "INDEX"
+			} else if dialect == DialectMySQL {
+				drop = c.kind
+			}
+
+			statements = []string{
+				fmt.Sprintf("ALTER TABLE %s DROP %s %s", table, drop, c.name),
+			}
+		}
+
+		changes = append(changes, SchemaChange{
+			Kind:       ExtraConstraint,
+			Table:      table,
+			Name:       c.name,
+			Actual:     c.sql,
+			Statements: statements,
+		})
+	}
+
+	return changes
+}
+
+// indexSignature describes what an index covers, so that indexes can be compared
+func indexSignature(index IndexDef) string {
+	s := fmt.Sprintf("(%s)", columnList(index.Columns))
+	if index.Unique {
+		s = "UNIQUE " + s
+	}
+	return s
+}
+
+// createIndexStatements returns the statement that creates the index, unless the
+// dialect doesn't support it
+func createIndexStatements(table string, index IndexDef, dialect Dialect) []string {
+	statement, err := index.createSQL(table, dialect, false)
+	if err != nil {
+		return nil
+	}
+	return []string{statement}
+}
+
+func dropIndexSQL(dialect Dialect, table, index string) string {
+	if dialect == DialectMySQL {
+		return fmt.Sprintf("DROP INDEX %s ON %s", index, table)
+	}
+	return fmt.Sprintf("DROP INDEX %s", index)
+}
+
+func columnList(columns []string) string {
+	return strings.Join(columns, ", ")
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// typeSynonyms maps the names that the dialects use for a type to a common name
+var typeSynonyms = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"mediumint":                   "integer",
+	"serial":                      "integer",
+	"serial4":                     "integer",
+	"int8":                        "bigint",
+	"bigserial":                   "bigint",
+	"serial8":                     "bigint",
+	"int2":                        "smallint",
+	"tinyint":                     "smallint",
+	"smallserial":                 "smallint",
+	"bool":                        "boolean",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"bpchar":                      "char",
+	"tinytext":                    "text",
+	"mediumtext":                  "text",
+	"longtext":                    "text",
+	"float":                       "real",
+	"float4":                      "real",
+	"double precision":            "double",
+	"float8":                      "double",
+	"decimal":                     "numeric",
+	"datetime":                    "timestamp",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"bytea":                       "blob",
+	"longblob":                    "blob",
+	"varbinary":                   "blob",
+	"binary":                      "blob",
+}
+
+// typeFamily normalizes a column type so that types can be compared across the
+// different ways dialects spell them. Lengths and precisions are ignored, since
+// Postgres doesn't report them in information_schema
+func typeFamily(typ string) string {
+	typ = strings.ToLower(strings.Join(strings.Fields(typ), " "))
+
+	// MySQL spells BOOLEAN as TINYINT(1)
+	if typ == "tinyint(1)" {
+		return "boolean"
+	}
+
+	if i := strings.Index(typ, "("); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+	typ = strings.TrimSuffix(typ, " unsigned")
+
+	if synonym, ok := typeSynonyms[typ]; ok {
+		return synonym
+	}
+	return typ
+}
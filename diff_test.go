@@ -0,0 +1,142 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "completed"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL UNIQUE,
+			completed BOOLEAN NOT NULL
+		`,
+	}
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	UserTable := Table[User]{
+		Name:      "users",
+		Columns:   []string{"id", "name"},
+		RawSchema: "id INTEGER PRIMARY KEY, name TEXT NOT NULL",
+	}
+
+	// When the tables don't exist yet...
+	diff, err := Diff(db, TodoTable, UserTable)
+	require.NoError(t, err)
+	require.Len(t, diff.Changes, 2)
+	assert.Equal(t, MissingTable, diff.Changes[0].Kind)
+	assert.Equal(t, "todos", diff.Changes[0].Table)
+
+	// (the statements create the missing tables)
+	for _, statement := range diff.Statements() {
+		db.MustExec(statement)
+	}
+
+	diff, err = Diff(db, TodoTable, UserTable)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+
+	// When the db matches the declared schema...
+	require.NoError(t, TodoTable.Drop(db))
+	require.NoError(t, TodoTable.Create(db))
+
+	diff, err = Diff(db, TodoTable, UserTable)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+
+	// When the db has drifted from the declared schema...
+	require.NoError(t, TodoTable.Drop(db))
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title VARCHAR(255),
+		notes TEXT
+	)`)
+	db.MustExec("CREATE INDEX todos_notes ON todos (notes)")
+	db.MustExec("CREATE TABLE tags (id INTEGER PRIMARY KEY)")
+
+	diff, err = Diff(db, TodoTable, UserTable)
+	require.NoError(t, err)
+
+	expected := []SchemaChange{
+		{
+			Kind:     ColumnType,
+			Table:    "todos",
+			Name:     "title",
+			Declared: "TEXT",
+			Actual:   "VARCHAR(255)",
+		},
+		{
+			Kind:     ColumnNullability,
+			Table:    "todos",
+			Name:     "title",
+			Declared: "NOT NULL",
+			Actual:   "NULL",
+		},
+		{
+			Kind:  MissingColumn,
+			Table: "todos",
+			Name:  "completed",
+			Statements: []string{
+				"ALTER TABLE todos ADD COLUMN completed BOOLEAN NOT NULL",
+			},
+		},
+		{
+			Kind:       ExtraColumn,
+			Table:      "todos",
+			Name:       "notes",
+			Statements: []string{"ALTER TABLE todos DROP COLUMN notes"},
+		},
+		{
+			Kind:       ExtraIndex,
+			Table:      "todos",
+			Name:       "todos_notes",
+			Statements: []string{"DROP INDEX todos_notes"},
+		},
+		{
+			Kind:     MissingConstraint,
+			Table:    "todos",
+			Declared: "UNIQUE (title)",
+		},
+		{
+			Kind:       ExtraTable,
+			Table:      "tags",
+			Statements: []string{"DROP TABLE tags"},
+		},
+	}
+	assert.Equal(t, SchemaDiff{Dialect: DialectSQLite, Changes: expected}, diff)
+
+	assert.Equal(
+		t,
+		`todos: column type title (declared "TEXT", actual "VARCHAR(255)")`,
+		diff.Changes[0].String(),
+	)
+}
+
+func TestTypeFamily(t *testing.T) {
+	assert.Equal(t, typeFamily("BIGSERIAL"), typeFamily("bigint"))
+	assert.Equal(t, typeFamily("VARCHAR(255)"), typeFamily("character varying"))
+	assert.Equal(t, typeFamily("BOOLEAN"), typeFamily("tinyint(1)"))
+	assert.Equal(t, typeFamily("DATETIME"), typeFamily("timestamp without time zone"))
+	assert.Equal(t, typeFamily("int(11) unsigned"), typeFamily("INTEGER"))
+	assert.NotEqual(t, typeFamily("TIMESTAMP"), typeFamily("timestamp with time zone"))
+	assert.NotEqual(t, typeFamily("TEXT"), typeFamily("INTEGER"))
+}
@@ -0,0 +1,116 @@
+package azamat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Production guards against destructive DDL. When it is set, Drop, DropIfExists,
+// Truncate, and Rename refuse to run unless their context allows it (see
+// AllowDestructive). Like Postgres, it is meant to be set once when the app starts
+var Production = false
+
+// ErrDestructive is returned when destructive DDL is run in Production without being
+// allowed
+var ErrDestructive = errors.New("destructive DDL is not allowed in production")
+
+type destructiveKey struct{}
+
+// AllowDestructive returns a copy of the context that allows destructive DDL to be
+// run, even in Production
+func AllowDestructive(ctx context.Context) context.Context {
+	return context.WithValue(ctx, destructiveKey{}, true)
+}
+
+func checkDestructive(ctx context.Context) error {
+	if allowed, _ := ctx.Value(destructiveKey{}).(bool); Production && !allowed {
+		return ErrDestructive
+	}
+	return nil
+}
+
+// Drop drops the table
+func (t Table[T]) Drop(runner Runner) error {
+	return t.DropContext(context.Background(), runner)
+}
+
+// DropIfExists drops the table, if it exists
+func (t Table[T]) DropIfExists(runner Runner) error {
+	return t.DropIfExistsContext(context.Background(), runner)
+}
+
+// Truncate deletes every row in the table and resets its autoincrement counter. On
+// Postgres, it also truncates the tables that have foreign keys to this one
+// (CASCADE). Since it doesn't go through Delete, it ignores soft deletes, scopes, and
+// auditing
+func (t Table[T]) Truncate(runner Runner) error {
+	return t.TruncateContext(context.Background(), runner)
+}
+
+// Rename renames the table in the db. The Table itself keeps its old name, so it has
+// to be updated too
+func (t Table[T]) Rename(runner Runner, name string) error {
+	return t.RenameContext(context.Background(), runner, name)
+}
+
+func (t Table[T]) DropContext(ctx context.Context, runner Runner) error {
+	return t.execDestructive(ctx, runner, fmt.Sprintf("DROP TABLE %s", t.Name))
+}
+
+func (t Table[T]) DropIfExistsContext(ctx context.Context, runner Runner) error {
+	drop := fmt.Sprintf("DROP TABLE IF EXISTS %s", t.Name)
+	return t.execDestructive(ctx, runner, drop)
+}
+
+func (t Table[T]) TruncateContext(ctx context.Context, runner Runner) error {
+	dialect := DialectOf(runner)
+	if dialect == "" {
+		dialect = t.dialect()
+	}
+
+	switch dialect {
+	case DialectPostgres:
+		truncate := fmt.Sprintf("TRUNCATE TABLE %s RESTART IDENTITY CASCADE", t.Name)
+		return t.execDestructive(ctx, runner, truncate)
+
+	case DialectMySQL:
+		truncate := fmt.Sprintf("TRUNCATE TABLE %s", t.Name)
+		return t.execDestructive(ctx, runner, truncate)
+	}
+
+	// SQLite doesn't have TRUNCATE
+	err := t.execDestructive(ctx, runner, fmt.Sprintf("DELETE FROM %s", t.Name))
+	if err != nil || dialect != DialectSQLite {
+		return err
+	}
+
+	// SQLite keeps AUTOINCREMENT counters in sqlite_sequence, which only exists once
+	// a table with an AUTOINCREMENT column has been created
+	var count int
+	err = withContext(runner).GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'sqlite_sequence'
+	`)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	resetSequence := "DELETE FROM sqlite_sequence WHERE name = ?"
+	_, err = withContext(runner).ExecContext(ctx, resetSequence, t.Name)
+	return err
+}
+
+func (t Table[T]) RenameContext(ctx context.Context, runner Runner, name string) error {
+	rename := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", t.Name, name)
+	return t.execDestructive(ctx, runner, rename)
+}
+
+func (t Table[T]) execDestructive(ctx context.Context, runner Runner, sql string) error {
+	if err := checkDestructive(ctx); err != nil {
+		return err
+	}
+
+	_, err := withContext(runner).ExecContext(ctx, sql)
+	return err
+}
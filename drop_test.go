@@ -0,0 +1,96 @@
+package azamat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableDrop(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	TodoTable := Table[Todo]{
+		Name:      "todos",
+		Columns:   []string{"id", "title"},
+		RawSchema: "id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL",
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	insert := TodoTable.Insert().Columns("title").Values("assist Borat")
+	_, err := insert.Run(db)
+	require.NoError(t, err)
+
+	// When truncating...
+	require.NoError(t, TodoTable.Truncate(db))
+
+	todos, err := TodoTable.GetAll(db)
+	require.NoError(t, err)
+	assert.Empty(t, todos)
+
+	// (the autoincrement counter starts over)
+	_, err = insert.Run(db)
+	require.NoError(t, err)
+
+	todo, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "assist Borat", todo.Title)
+
+	// When renaming (as part of a transaction)...
+	err = CommitTransaction(db, func(tx *sqlx.Tx) error {
+		return TodoTable.Rename(tx, "tasks")
+	})
+	require.NoError(t, err)
+
+	TaskTable := TodoTable
+	TaskTable.Name = "tasks"
+
+	_, err = TaskTable.GetByID(db, 1)
+	require.NoError(t, err)
+
+	// When dropping...
+	require.NoError(t, TaskTable.Drop(db))
+	require.Error(t, TaskTable.Drop(db))
+	require.NoError(t, TaskTable.DropIfExists(db))
+}
+
+func TestTableDropInProduction(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	TodoTable := Table[struct{ ID int }]{
+		Name:      "todos",
+		RawSchema: "id INTEGER PRIMARY KEY",
+	}
+	require.NoError(t, TodoTable.Create(db))
+
+	Production = true
+	defer func() { Production = false }()
+
+	// When destructive DDL isn't allowed...
+	require.Equal(t, ErrDestructive, TodoTable.Truncate(db))
+	require.Equal(t, ErrDestructive, TodoTable.Rename(db, "tasks"))
+	require.Equal(t, ErrDestructive, TodoTable.Drop(db))
+	require.Equal(t, ErrDestructive, TodoTable.DropIfExists(db))
+
+	names, err := TableNames(db)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"todos"}, names)
+
+	// When it is allowed...
+	ctx := AllowDestructive(context.Background())
+	require.NoError(t, TodoTable.DropContext(ctx, db))
+
+	names, err = TableNames(db)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
@@ -0,0 +1,117 @@
+package azamat
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// String match modes for ExampleOptions
+const (
+	// MatchExact compares strings with "="
+	MatchExact = ""
+
+	// MatchPrefix matches strings that start with the value
+	MatchPrefix = "prefix"
+
+	// MatchSuffix matches strings that end with the value
+	MatchSuffix = "suffix"
+
+	// MatchContains matches strings that contain the value
+	MatchContains = "contains"
+)
+
+// ExampleOptions configures WhereExample
+type ExampleOptions struct {
+	// Columns are compared even when their fields have zero values, eg: to find todos
+	// that aren't completed. A nil pointer in Columns matches NULL
+	Columns []string
+
+	// IncludeZero compares every column, even when its field has the zero value
+	IncludeZero bool
+
+	// NilAsNull makes nil pointer fields match NULL. By default, they are ignored.
+	// Non-nil pointers are always compared, even when they point to a zero value
+	NilAsNull bool
+
+	// StringMatch is how string fields are compared. Defaults to MatchExact. The other
+	// modes use LIKE, with the value's wildcards (% and _) escaped
+	StringMatch string
+}
+
+// likeEscape escapes LIKE patterns. It isn't a backslash, because MySQL treats
+// backslashes in string literals as escapes too
+const likeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(
+	likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_",
+)
+
+// WhereExample returns a predicate that matches the rows that look like the example:
+// each of the table's Columns whose field in the example isn't the zero value must be
+// equal to it. It is meant for search endpoints, where any field can be left out:
+//
+//	example := Todo{Title: "milk", Completed: true}
+//	options := ExampleOptions{StringMatch: MatchContains}
+//	TodoTable.Select().Where(TodoTable.WhereExample(example, options))
+//
+// Fields of a nil embedded struct pointer are unset. The columns are qualified with the
+// table's name (or alias), so the predicate can be used in joins. If no field is set,
+// it matches every row
+func (t Table[T]) WhereExample(example T, options ExampleOptions) sq.Sqlizer {
+	switch options.StringMatch {
+	case MatchExact, MatchPrefix, MatchSuffix, MatchContains:
+	default:
+		err := fmt.Errorf("unknown string match %q", options.StringMatch)
+		return errPredicate{err}
+	}
+
+	v := reflect.ValueOf(example)
+
+	predicates := sq.And{}
+	for _, column := range t.Columns {
+		field, err := readRowField(v, column)
+		if err != nil {
+			return errPredicate{err}
+		}
+
+		explicit := options.IncludeZero || contains(options.Columns, column)
+		qualified := t.ref() + "." + column
+
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				if explicit || options.NilAsNull {
+					predicates = append(predicates, sq.Eq{qualified: nil})
+				}
+				continue
+			}
+			field = field.Elem()
+		} else if field.IsZero() && !explicit {
+			continue
+		}
+
+		predicates = append(predicates, examplePredicate(qualified, field, options))
+	}
+
+	return predicates
+}
+
+func examplePredicate(column string, field reflect.Value, options ExampleOptions) sq.Sqlizer {
+	if field.Kind() != reflect.String || options.StringMatch == MatchExact {
+		return sq.Eq{column: field.Interface()}
+	}
+
+	pattern := likeEscaper.Replace(field.String())
+	switch options.StringMatch {
+	case MatchPrefix:
+		pattern += "%"
+	case MatchSuffix:
+		pattern = "%" + pattern
+	case MatchContains:
+		pattern = "%" + pattern + "%"
+	}
+
+	return sq.Expr(column+" LIKE ? ESCAPE '"+likeEscape+"'", pattern)
+}
@@ -0,0 +1,154 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereExample(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+		Priority  *int
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "completed", "priority"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			completed BOOLEAN NOT NULL,
+			priority INTEGER
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO todos (title, completed, priority) VALUES
+		('buy milk', true, 1),
+		('buy 100% milk', false, 0),
+		('buy bread', false, NULL),
+		('sell milk', true, NULL)
+	`)
+
+	titles := func(example Todo, options ExampleOptions) []string {
+		todos, err := TodoTable.Select().
+			Where(TodoTable.WhereExample(example, options)).
+			OrderBy("id").
+			All(db)
+		require.NoError(t, err)
+
+		titles := []string{}
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		return titles
+	}
+
+	// When no field is set, every row matches
+	require.Len(t, titles(Todo{}, ExampleOptions{}), 4)
+
+	// When some fields are set, zero values are ignored
+	require.Equal(
+		t,
+		[]string{"buy milk", "sell milk"},
+		titles(Todo{Completed: true}, ExampleOptions{}),
+	)
+
+	// When a zero value is explicitly compared
+	require.Equal(
+		t,
+		[]string{"buy 100% milk", "buy bread"},
+		titles(Todo{}, ExampleOptions{Columns: []string{"completed"}}),
+	)
+
+	// When a pointer points to a zero value, it is compared
+	zero := 0
+	require.Equal(t, []string{"buy 100% milk"}, titles(Todo{Priority: &zero}, ExampleOptions{}))
+
+	// When nil pointers match NULL
+	require.Equal(
+		t,
+		[]string{"sell milk"},
+		titles(Todo{Completed: true}, ExampleOptions{NilAsNull: true}),
+	)
+
+	// When strings are matched by pattern
+	require.Equal(
+		t,
+		[]string{"buy milk", "buy 100% milk", "buy bread"},
+		titles(Todo{Title: "buy"}, ExampleOptions{StringMatch: MatchPrefix}),
+	)
+	require.Equal(
+		t,
+		[]string{"buy milk", "buy 100% milk", "sell milk"},
+		titles(Todo{Title: "milk"}, ExampleOptions{StringMatch: MatchSuffix}),
+	)
+	require.Equal(
+		t,
+		[]string{"buy 100% milk"},
+		titles(Todo{Title: "0% m"}, ExampleOptions{StringMatch: MatchContains}),
+	)
+
+	// When every column is compared, even the zero ID
+	require.Empty(t, titles(Todo{Title: "buy milk"}, ExampleOptions{IncludeZero: true}))
+
+	// When the string match is unknown
+	_, err := TodoTable.Select().
+		Where(TodoTable.WhereExample(Todo{}, ExampleOptions{StringMatch: "fuzzy"})).
+		All(db)
+	require.EqualError(t, err, `unknown string match "fuzzy"`)
+
+	// When the columns would be ambiguous in a join
+	sql, _, err := TodoTable.WhereExample(Todo{ID: 1}, ExampleOptions{}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "(todos.id = ?)", sql)
+
+	// When a column has no field
+	TodoTable.Columns = append(TodoTable.Columns, "due_at")
+	_, err = TodoTable.Select().
+		Where(TodoTable.WhereExample(Todo{}, ExampleOptions{})).
+		All(db)
+	require.Error(t, err)
+}
+
+func TestWhereExampleEmbedded(t *testing.T) {
+	type Timestamps struct {
+		CreatedAt string `db:"created_at"`
+	}
+
+	type Todo struct {
+		ID    int
+		Title string
+		*Timestamps
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "created_at"},
+	}
+
+	// When the embedded struct is a nil pointer, its fields are unset
+	sql, args, err := TodoTable.WhereExample(Todo{Title: "milk"}, ExampleOptions{}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "(todos.title = ?)", sql)
+	require.Equal(t, []any{"milk"}, args)
+
+	// When it isn't
+	example := Todo{Timestamps: &Timestamps{CreatedAt: "2022-01-01"}}
+	sql, args, err = TodoTable.WhereExample(example, ExampleOptions{}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "(todos.created_at = ?)", sql)
+	require.Equal(t, []any{"2022-01-01"}, args)
+
+	// When it is aliased
+	sql, _, err = TodoTable.As("t").WhereExample(Todo{ID: 1}, ExampleOptions{}).ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "(t.id = ?)", sql)
+}
@@ -0,0 +1,417 @@
+package azamat
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// FilterError is a problem with a filter expression. Pos is the byte offset in the
+// expression where the problem is
+type FilterError struct {
+	Pos     int
+	Message string
+}
+
+func (e FilterError) Error() string {
+	return fmt.Sprintf("filter: position %d: %s", e.Pos, e.Message)
+}
+
+// maxFilterDepth limits how deeply filters can be nested, since they come from users
+const maxFilterDepth = 32
+
+// ParseFilter compiles a filter expression into a predicate, eg:
+//
+//	status = "open" AND (priority > 2 OR assignee IS NULL)
+//
+// Identifiers have to be in the table's Columns. Values are always bound as args
+// (never put in the SQL), after being converted to the Go type of the column's field.
+// The expression supports:
+//
+//   - comparisons: =, !=, <>, <, <=, >, >=
+//   - [NOT] LIKE, [NOT] IN (...), and IS [NOT] NULL
+//   - AND, OR, NOT, and parentheses
+//   - strings in single or double quotes (with backslash escapes), numbers, true,
+//     and false
+//
+// Keywords are case insensitive, and columns are qualified with the table's name (or
+// alias). Errors are FilterErrors
+func (t Table[T]) ParseFilter(filter string) (sq.Sqlizer, error) {
+	tokens, err := lexFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var row T
+	p := filterParser{
+		tokens:  tokens,
+		columns: t.Columns,
+		ref:     t.ref(),
+		row:     reflect.TypeOf(row),
+	}
+
+	predicate, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, tok.errorf("unexpected %s", tok)
+	}
+	return predicate, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type filterToken struct {
+	kind  tokenKind
+	text  string // for strings, the unquoted value
+	pos   int
+	upper string // the upper cased text of identifiers, to match keywords
+}
+
+func (tok filterToken) String() string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenString:
+		return fmt.Sprintf("%q", tok.text)
+	}
+	return tok.text
+}
+
+func (tok filterToken) errorf(format string, args ...any) error {
+	return FilterError{tok.pos, fmt.Sprintf(format, args...)}
+}
+
+func (tok filterToken) is(keyword string) bool {
+	return tok.kind == tokenIdent && tok.upper == keyword
+}
+
+func lexFilter(filter string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: tokenLParen, text: "(", pos: i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: tokenRParen, text: ")", pos: i})
+			i++
+
+		case c == ',':
+			tokens = append(tokens, filterToken{kind: tokenComma, text: ",", pos: i})
+			i++
+
+		case strings.ContainsRune("=!<>", rune(c)):
+			op := string(c)
+			if i+1 < len(filter) {
+				switch filter[i : i+2] {
+				case "!=", "<>", "<=", ">=":
+					op = filter[i : i+2]
+				}
+			}
+			if op == "!" {
+				return nil, FilterError{i, "unexpected !"}
+			}
+			tokens = append(tokens, filterToken{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			i++
+			for ; i < len(filter) && filter[i] != c; i++ {
+				if filter[i] == '\\' && i+1 < len(filter) {
+					i++
+				}
+				b.WriteByte(filter[i])
+			}
+			if i == len(filter) {
+				return nil, FilterError{start, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, filterToken{
+				kind: tokenString, text: b.String(), pos: start,
+			})
+
+		case c == '-' || c == '.' || isDigit(c):
+			i++
+			for i < len(filter) && (isDigit(filter[i]) || filter[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, filterToken{
+				kind: tokenNumber, text: filter[start:i], pos: start,
+			})
+
+		case c == '_' || unicode.IsLetter(rune(c)):
+			for i < len(filter) && (filter[i] == '_' || filter[i] == '.' ||
+				isDigit(filter[i]) || unicode.IsLetter(rune(filter[i]))) {
+				i++
+			}
+			text := filter[start:i]
+			tokens = append(tokens, filterToken{
+				kind: tokenIdent, text: text, pos: start, upper: strings.ToUpper(text),
+			})
+
+		default:
+			return nil, FilterError{i, fmt.Sprintf("unexpected %q", c)}
+		}
+	}
+
+	tokens = append(tokens, filterToken{kind: tokenEOF, pos: len(filter)})
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type filterParser struct {
+	tokens  []filterToken
+	columns []string
+	ref     string // the name (or alias) of the table, which qualifies the columns
+	row     reflect.Type
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[0]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[0]
+	if tok.kind != tokenEOF {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *filterParser) accept(keyword string) bool {
+	if p.peek().is(keyword) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(kind tokenKind, what string) (filterToken, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, tok.errorf("expected %s, got %s", what, tok)
+	}
+	return tok, nil
+}
+
+// or := and { OR and }
+func (p *filterParser) or(depth int) (sq.Sqlizer, error) {
+	predicate, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	or := sq.Or{predicate}
+	for p.accept("OR") {
+		predicate, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, predicate)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+// and := not { AND not }
+func (p *filterParser) and(depth int) (sq.Sqlizer, error) {
+	predicate, err := p.not(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	and := sq.And{predicate}
+	for p.accept("AND") {
+		predicate, err := p.not(depth)
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, predicate)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// not := NOT not | ( or ) | comparison
+func (p *filterParser) not(depth int) (sq.Sqlizer, error) {
+	if depth > maxFilterDepth {
+		return nil, p.peek().errorf("filter is nested too deeply")
+	}
+
+	if p.accept("NOT") {
+		predicate, err := p.not(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return sq.Expr("NOT (?)", predicate), nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		predicate, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		return predicate, nil
+	}
+
+	return p.comparison()
+}
+
+// comparison := column op value | column IS [NOT] NULL | column [NOT] IN (values) |
+// column [NOT] LIKE string
+func (p *filterParser) comparison() (sq.Sqlizer, error) {
+	tok, err := p.expect(tokenIdent, "a column")
+	if err != nil {
+		return nil, err
+	}
+
+	column := tok.text
+	if !contains(p.columns, column) {
+		return nil, tok.errorf("unknown column %s", column)
+	}
+	typ := columnType(p.row, column)
+	qualified := p.ref + "." + column
+
+	if p.accept("IS") {
+		not := p.accept("NOT")
+		if tok := p.next(); !tok.is("NULL") {
+			return nil, tok.errorf("expected NULL, got %s", tok)
+		}
+
+		if not {
+			return sq.NotEq{qualified: nil}, nil
+		}
+		return sq.Eq{qualified: nil}, nil
+	}
+
+	not := p.accept("NOT")
+
+	switch {
+	case p.accept("IN"):
+		if _, err := p.expect(tokenLParen, "("); err != nil {
+			return nil, err
+		}
+
+		var values []any
+		for {
+			value, err := p.value(typ)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+
+		if _, err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+
+		if not {
+			return sq.NotEq{qualified: values}, nil
+		}
+		return sq.Eq{qualified: values}, nil
+
+	case p.accept("LIKE"):
+		tok, err := p.expect(tokenString, "a string")
+		if err != nil {
+			return nil, err
+		}
+
+		if not {
+			return sq.NotLike{qualified: tok.text}, nil
+		}
+		return sq.Like{qualified: tok.text}, nil
+
+	case not:
+		tok := p.next()
+		return nil, tok.errorf("expected IN or LIKE, got %s", tok)
+	}
+
+	op, err := p.expect(tokenOperator, "an operator")
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.value(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.text {
+	case "=":
+		return sq.Eq{qualified: value}, nil
+	case "!=", "<>":
+		return sq.NotEq{qualified: value}, nil
+	case "<":
+		return sq.Lt{qualified: value}, nil
+	case "<=":
+		return sq.LtOrEq{qualified: value}, nil
+	case ">":
+		return sq.Gt{qualified: value}, nil
+	default: // >=
+		return sq.GtOrEq{qualified: value}, nil
+	}
+}
+
+// value := string | number | TRUE | FALSE
+func (p *filterParser) value(typ reflect.Type) (any, error) {
+	tok := p.next()
+
+	switch {
+	case tok.kind == tokenString, tok.kind == tokenNumber, tok.is("TRUE"), tok.is("FALSE"):
+	case tok.is("NULL"):
+		return nil, tok.errorf("use IS NULL to compare with NULL")
+	default:
+		return nil, tok.errorf("expected a value, got %s", tok)
+	}
+
+	value, err := parseValue(tok.text, typ)
+	if err != nil {
+		return nil, tok.errorf("%s", err)
+	}
+	return value, nil
+}
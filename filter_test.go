@@ -0,0 +1,206 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	type Todo struct {
+		ID       int
+		Status   string
+		Priority int
+		Assignee *string
+		Archived bool
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "status", "priority", "assignee", "archived"},
+	}
+
+	tests := []struct {
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		{`status = "open"`, "todos.status = ?", []interface{}{"open"}},
+		{
+			`status = "open" AND (priority > 2 OR assignee IS NULL)`,
+			"(todos.status = ? AND (todos.priority > ? OR todos.assignee IS NULL))",
+			[]interface{}{"open", int64(2)},
+		},
+		{
+			`status != 'done' and not archived = true or id <= 10`,
+			"((todos.status <> ? AND NOT (todos.archived = ?)) OR todos.id <= ?)",
+			[]interface{}{"done", true, int64(10)},
+		},
+		{
+			`priority IN (1, 2,3)`,
+			"todos.priority IN (?,?,?)",
+			[]interface{}{int64(1), int64(2), int64(3)},
+		},
+		{`status NOT IN ("a")`, "todos.status NOT IN (?)", []interface{}{"a"}},
+		{`assignee LIKE "bo%"`, "todos.assignee LIKE ?", []interface{}{"bo%"}},
+		{`assignee NOT LIKE "bo%"`, "todos.assignee NOT LIKE ?", []interface{}{"bo%"}},
+		{`assignee IS NOT NULL`, "todos.assignee IS NOT NULL", nil},
+		{`priority>=-1`, "todos.priority >= ?", []interface{}{int64(-1)}},
+		{`status = "say \"hi\""`, "todos.status = ?", []interface{}{`say "hi"`}},
+
+		// When a value would be an injection if it were interpolated
+		{
+			`status = "'; DROP TABLE todos; --"`,
+			"todos.status = ?",
+			[]interface{}{"'; DROP TABLE todos; --"},
+		},
+	}
+
+	for _, test := range tests {
+		predicate, err := TodoTable.ParseFilter(test.filter)
+		require.NoError(t, err, test.filter)
+
+		sql, args, err := predicate.ToSql()
+		require.NoError(t, err)
+		require.Equal(t, test.sql, sql, test.filter)
+		require.Equal(t, test.args, args, test.filter)
+	}
+
+	errors := []struct {
+		filter string
+		err    string
+	}{
+		{`password = "hunter2"`, "filter: position 0: unknown column password"},
+		{`status = `, "filter: position 9: expected a value, got end of filter"},
+		{`priority = "high"`, `filter: position 11: "high" is not an integer`},
+		{`status = "open`, "filter: position 9: unterminated string"},
+		{`(status = "a"`, "filter: position 13: expected ), got end of filter"},
+		{`status = "a" status`, "filter: position 13: unexpected status"},
+		{`assignee = NULL`, "filter: position 11: use IS NULL to compare with NULL"},
+		{`status NOT = "a"`, "filter: position 11: expected IN or LIKE, got ="},
+		{`status ; 1`, `filter: position 7: unexpected ';'`},
+		{`1 = status`, "filter: position 0: expected a column, got 1"},
+	}
+
+	for _, test := range errors {
+		_, err := TodoTable.ParseFilter(test.filter)
+		require.EqualError(t, err, test.err, test.filter)
+	}
+
+	// When the filter is nested too deeply
+	deep := ""
+	for i := 0; i < 100; i++ {
+		deep += "("
+	}
+	_, err := TodoTable.ParseFilter(deep + "id = 1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "nested too deeply")
+
+	deep = ""
+	for i := 0; i < 100; i++ {
+		deep += "NOT "
+	}
+	_, err = TodoTable.ParseFilter(deep + "id = 1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "nested too deeply")
+}
+
+func TestParseFilterQuery(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID       int
+		Status   string
+		Priority int
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "status", "priority"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			status TEXT NOT NULL,
+			priority INTEGER NOT NULL
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO todos (status, priority) VALUES
+		('open', 1), ('open', 3), ('done', 5)
+	`)
+
+	filter, err := TodoTable.ParseFilter(`status = "open" AND NOT priority < 2`)
+	require.NoError(t, err)
+
+	todos, err := TodoTable.Select().Where(filter).All(db)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{{ID: 2, Status: "open", Priority: 3}}, todos)
+}
+
+func TestParseFilterJoined(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID     int
+		Status string
+	}
+
+	type Todo struct {
+		ID       int
+		Status   string
+		AuthorID int `db:"author_id"`
+	}
+
+	UserTable := Table[User]{
+		Name:    "users",
+		Columns: []string{"id", "status"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			status TEXT NOT NULL
+		`,
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "status", "author_id"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			status TEXT NOT NULL,
+			author_id INTEGER NOT NULL
+		`,
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO users (status) VALUES ('active'), ('banned')`)
+	db.MustExec(`INSERT INTO todos (status, author_id) VALUES
+		('open', 1), ('done', 1), ('open', 2)
+	`)
+
+	filter, err := TodoTable.ParseFilter(`status = "open" AND id > 0`)
+	require.NoError(t, err)
+
+	// When the query joins a table with the same columns, they aren't ambiguous
+	todos, err := TodoTable.Select().
+		Join("users ON users.id = todos.author_id").
+		Where("users.status = ?", "active").
+		Where(filter).
+		All(db)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{{1, "open", 1}}, todos)
+
+	// When the table is aliased, the columns use the alias
+	filter, err = TodoTable.As("t").ParseFilter(`status = "open"`)
+	require.NoError(t, err)
+
+	todos, err = TodoTable.As("t").Select().Where(filter).All(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+
+	sql, _, err := filter.ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "t.status = ?", sql)
+}
@@ -0,0 +1,414 @@
+package azamat
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Nullable styles for generated code
+const (
+	// NullablePointer represents nullable columns as pointers, eg: *string
+	NullablePointer = "pointer"
+
+	// NullableSQL represents nullable columns with the database/sql null types, eg:
+	// sql.NullString. Types that don't have one fall back to pointers
+	NullableSQL = "sql"
+)
+
+// GenConfig configures Generate
+type GenConfig struct {
+	// Package is the name of the generated package. Defaults to "models"
+	Package string
+
+	// Tables are the tables to generate code for. Defaults to every table
+	Tables []string
+
+	// Types maps column types (eg: "uuid") to Go types, on top of the defaults. Column
+	// types are case insensitive. A Go type can be qualified with its import path, eg:
+	// "github.com/google/uuid.UUID". Numeric and decimal columns are strings by
+	// default, since a float64 would lose precision
+	Types map[string]string
+
+	// Nullable is how nullable columns are represented. Defaults to NullablePointer
+	Nullable string
+
+	// StructName turns a table name into the name of its row struct. Defaults to the
+	// singular, camel cased table name, eg: "todo_items" becomes "TodoItem"
+	StructName func(table string) string
+
+	// FieldName turns a column name into the name of its struct field. Defaults to
+	// the camel cased column name, eg: "user_id" becomes "UserID"
+	FieldName func(column string) string
+}
+
+// defaultGoTypes maps type families (see typeFamily) to Go types
+var defaultGoTypes = map[string]string{
+	"integer":     "int64",
+	"bigint":      "int64",
+	"smallint":    "int64",
+	"boolean":     "bool",
+	"text":        "string",
+	"varchar":     "string",
+	"char":        "string",
+	"uuid":        "string",
+	"real":        "float64",
+	"double":      "float64",
+	"numeric":     "string",
+	"timestamp":   "time.Time",
+	"timestamptz": "time.Time",
+	"date":        "time.Time",
+	"blob":        "[]byte",
+	"json":        "[]byte",
+	"jsonb":       "[]byte",
+}
+
+// sqlNullTypes are the database/sql types for nullable columns
+var sqlNullTypes = map[string]string{
+	"int64":     "sql.NullInt64",
+	"bool":      "sql.NullBool",
+	"string":    "sql.NullString",
+	"float64":   "sql.NullFloat64",
+	"time.Time": "sql.NullTime",
+}
+
+// Generate introspects the db that the runner is connected to and generates Go code
+// for its tables: a row struct with db tags and a Table variable with its Columns,
+// IDColumn, and Schema. If the db is Postgres, the Tables are too. The code is gofmt'd
+func Generate(runner Runner, config GenConfig) ([]byte, error) {
+	if config.Package == "" {
+		config.Package = "models"
+	}
+	if config.StructName == nil {
+		config.StructName = func(table string) string {
+			return singular(CamelCase(table))
+		}
+	}
+	if config.FieldName == nil {
+		config.FieldName = CamelCase
+	}
+
+	types := make(map[string]string, len(config.Types))
+	for columnType, goType := range config.Types {
+		types[strings.ToLower(columnType)] = goType
+	}
+	config.Types = types
+
+	tables := config.Tables
+	if len(tables) == 0 {
+		var err error
+		if tables, err = TableNames(runner); err != nil {
+			return nil, err
+		}
+	}
+
+	g := generator{
+		config:   config,
+		imports:  map[string]bool{},
+		structs:  map[string]string{},
+		postgres: DialectOf(runner) == DialectPostgres,
+	}
+	for _, table := range tables {
+		schema, err := Introspect(runner, table)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := g.table(table, schema); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.source()
+}
+
+type generator struct {
+	config  GenConfig
+	imports map[string]bool
+	body    bytes.Buffer
+
+	// structs maps the names of the generated structs to their tables, so two tables
+	// can't generate the same struct
+	structs map[string]string
+
+	// postgres is set when the tables are from a Postgres db, so they need Postgres
+	// placeholders
+	postgres bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) table(table string, schema Schema) error {
+	name := g.config.StructName(table)
+	if other, ok := g.structs[name]; ok {
+		return fmt.Errorf(
+			"tables %s and %s would both generate the struct %s",
+			other, table, name,
+		)
+	}
+	g.structs[name] = table
+
+	g.printf("type %s struct {\n", name)
+	for _, column := range schema.Columns {
+		goType, err := g.goType(column)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", table, column.Name, err)
+		}
+
+		field := g.config.FieldName(column.Name)
+		g.printf("%s %s `db:%q`\n", field, goType, column.Name)
+	}
+	g.printf("}\n\n")
+
+	g.imports[azamatImportPath] = true
+
+	g.printf("var %sTable = azamat.Table[%s]{\n", name, name)
+	g.printf("Name: %q,\n", table)
+
+	g.printf("Columns: []string{\n")
+	for _, column := range schema.Columns {
+		g.printf("%q,\n", column.Name)
+	}
+	g.printf("},\n")
+
+	if len(schema.PrimaryKey) == 1 && schema.PrimaryKey[0] != "id" {
+		g.printf("IDColumn: %q,\n", schema.PrimaryKey[0])
+	}
+
+	if g.postgres {
+		g.printf("Postgres: true,\n")
+	}
+
+	g.printf("Schema: &azamat.Schema{\n")
+	g.schema(schema)
+	g.printf("},\n")
+
+	g.printf("}\n\n")
+	return nil
+}
+
+func (g *generator) schema(schema Schema) {
+	g.printf("Columns: []azamat.ColumnDef{\n")
+	for _, c := range schema.Columns {
+		g.printf("{Name: %q, Type: %q", c.Name, c.Type)
+		if c.Nullable {
+			g.printf(", Nullable: true")
+		}
+		if c.Default != "" {
+			g.printf(", Default: %q", c.Default)
+		}
+		if c.AutoIncrement {
+			g.printf(", AutoIncrement: true")
+		}
+		g.printf("},\n")
+	}
+	g.printf("},\n")
+
+	if len(schema.PrimaryKey) > 0 {
+		g.printf("PrimaryKey: %s,\n", stringSlice(schema.PrimaryKey))
+	}
+
+	if len(schema.Uniques) > 0 {
+		g.printf("Uniques: []azamat.UniqueDef{\n")
+		for _, u := range schema.Uniques {
+			g.printf("{")
+			if u.Name != "" {
+				g.printf("Name: %q, ", u.Name)
+			}
+			g.printf("Columns: %s},\n", stringSlice(u.Columns))
+		}
+		g.printf("},\n")
+	}
+
+	if len(schema.ForeignKeys) > 0 {
+		g.printf("ForeignKeys: []azamat.ForeignKeyDef{\n")
+		for _, fk := range schema.ForeignKeys {
+			g.printf("{")
+			if fk.Name != "" {
+				g.printf("Name: %q, ", fk.Name)
+			}
+			g.printf(
+				"Columns: %s, RefTable: %q, RefColumns: %s",
+				stringSlice(fk.Columns),
+				fk.RefTable,
+				stringSlice(fk.RefColumns),
+			)
+			if fk.OnDelete != "" {
+				g.printf(", OnDelete: %q", fk.OnDelete)
+			}
+			if fk.OnUpdate != "" {
+				g.printf(", OnUpdate: %q", fk.OnUpdate)
+			}
+			g.printf("},\n")
+		}
+		g.printf("},\n")
+	}
+
+	if len(schema.Indexes) > 0 {
+		g.printf("Indexes: []azamat.IndexDef{\n")
+		for _, index := range schema.Indexes {
+			g.printf("{Name: %q, Columns: %s", index.Name, stringSlice(index.Columns))
+			if index.Unique {
+				g.printf(", Unique: true")
+			}
+			g.printf("},\n")
+		}
+		g.printf("},\n")
+	}
+}
+
+// goType returns the Go type of a column, and adds whatever it needs to the imports
+func (g *generator) goType(column ColumnDef) (string, error) {
+	family := typeFamily(column.Type)
+
+	goType, ok := g.config.Types[strings.ToLower(column.Type)]
+	if !ok {
+		goType, ok = g.config.Types[family]
+	}
+	if !ok {
+		goType, ok = defaultGoTypes[family]
+	}
+	if !ok {
+		goType = "any"
+	}
+
+	goType, importPath := qualify(goType)
+
+	// Slices and interfaces can already be nil
+	if !column.Nullable || strings.HasPrefix(goType, "[]") || goType == "any" {
+		g.use(importPath)
+		return goType, nil
+	}
+
+	switch g.config.Nullable {
+	case "", NullablePointer:
+	case NullableSQL:
+		if nullType, ok := sqlNullTypes[goType]; ok {
+			g.use("database/sql")
+			return nullType, nil
+		}
+	default:
+		return "", fmt.Errorf("unknown nullable style %q", g.config.Nullable)
+	}
+
+	g.use(importPath)
+	return "*" + goType, nil
+}
+
+func (g *generator) use(importPath string) {
+	if importPath != "" {
+		g.imports[importPath] = true
+	}
+}
+
+// qualify turns a Go type that is qualified with its import path (eg:
+// "github.com/google/uuid.UUID") into one that is qualified with its package name
+// (eg: "uuid.UUID"). It also returns the import path, if the type needs one
+func qualify(goType string) (string, string) {
+	if goType == "time.Time" {
+		return goType, "time"
+	}
+
+	i := strings.LastIndex(goType, ".")
+	if i < 0 || !strings.Contains(goType[:i], "/") {
+		return goType, ""
+	}
+
+	importPath, name := goType[:i], goType[i+1:]
+	return path.Base(importPath) + "." + name, importPath
+}
+
+func (g *generator) source() ([]byte, error) {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by azamat gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", g.config.Package)
+
+	// The standard library is imported first, like goimports does it
+	var std, others []string
+	for importPath := range g.imports {
+		if strings.Contains(strings.Split(importPath, "/")[0], ".") {
+			others = append(others, importPath)
+		} else {
+			std = append(std, importPath)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	fmt.Fprintf(&src, "import (\n")
+	for _, importPath := range std {
+		fmt.Fprintf(&src, "%q\n", importPath)
+	}
+	if len(std) > 0 {
+		fmt.Fprintf(&src, "\n")
+	}
+	for _, importPath := range others {
+		fmt.Fprintf(&src, "%q\n", importPath)
+	}
+	fmt.Fprintf(&src, ")\n\n")
+
+	src.Write(g.body.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func stringSlice(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprintf("[]string{%s}", strings.Join(quoted, ", "))
+}
+
+// initialisms are the words that Go style writes in all caps
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// CamelCase turns a snake_case name into an exported Go identifier, eg: "user_id"
+// becomes "UserID"
+func CamelCase(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' ' || r == '.'
+	}) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	s := b.String()
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// singular makes a plural English word singular, at least for the common cases
+func singular(word string) string {
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 3:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"),
+		strings.HasSuffix(word, "xes"),
+		strings.HasSuffix(word, "ches"),
+		strings.HasSuffix(word, "shes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
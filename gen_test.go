@@ -0,0 +1,203 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	db.MustExec(`CREATE TABLE users (
+		user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL UNIQUE
+	)`)
+	db.MustExec(`CREATE TABLE todo_items (
+		id INTEGER PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		completed BOOLEAN NOT NULL DEFAULT false,
+		user_id INTEGER REFERENCES users(user_id),
+		due_at TIMESTAMP,
+		external_id UUID
+	)`)
+	db.MustExec("CREATE INDEX todo_items_due ON todo_items (due_at)")
+
+	src, err := Generate(db, GenConfig{
+		Package: "db",
+		Tables:  []string{"todo_items"},
+		Types:   map[string]string{"uuid": "github.com/google/uuid.UUID"},
+	})
+	require.NoError(t, err)
+
+	expected := `// Code generated by azamat gen. DO NOT EDIT.
+
+package db
+
+import (
+	"time"
+
+	"github.com/NickDubelman/azamat"
+	"github.com/google/uuid"
+)
+
+type TodoItem struct {
+	ID         int64      ` + "`" + `db:"id"` + "`" + `
+	Title      string     ` + "`" + `db:"title"` + "`" + `
+	Completed  bool       ` + "`" + `db:"completed"` + "`" + `
+	UserID     *int64     ` + "`" + `db:"user_id"` + "`" + `
+	DueAt      *time.Time ` + "`" + `db:"due_at"` + "`" + `
+	ExternalID *uuid.UUID ` + "`" + `db:"external_id"` + "`" + `
+}
+
+var TodoItemTable = azamat.Table[TodoItem]{
+	Name: "todo_items",
+	Columns: []string{
+		"id",
+		"title",
+		"completed",
+		"user_id",
+		"due_at",
+		"external_id",
+	},
+	Schema: &azamat.Schema{
+		Columns: []azamat.ColumnDef{
+			{Name: "id", Type: "INTEGER"},
+			{Name: "title", Type: "VARCHAR(255)"},
+			{Name: "completed", Type: "BOOLEAN", Default: "false"},
+			{Name: "user_id", Type: "INTEGER", Nullable: true},
+			{Name: "due_at", Type: "TIMESTAMP", Nullable: true},
+			{Name: "external_id", Type: "UUID", Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		ForeignKeys: []azamat.ForeignKeyDef{
+			{Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"user_id"}},
+		},
+		Indexes: []azamat.IndexDef{
+			{Name: "todo_items_due", Columns: []string{"due_at"}},
+		},
+	},
+}
+`
+	assert.Equal(t, expected, string(src))
+
+	// When using database/sql's null types for every table...
+	src, err = Generate(db, GenConfig{Nullable: NullableSQL})
+	require.NoError(t, err)
+
+	assert.Contains(t, string(src), "\"database/sql\"")
+	assert.Contains(t, string(src), "DueAt      sql.NullTime")
+	assert.NotContains(t, string(src), "\"time\"")
+	assert.Contains(t, string(src), "UserID     sql.NullInt64")
+	assert.Contains(t, string(src), "ExternalID sql.NullString")
+	assert.Contains(t, string(src), "type User struct")
+	assert.Contains(t, string(src), `IDColumn: "user_id",`)
+	assert.Contains(t, string(src), `AutoIncrement: true`)
+
+	// When the naming rules are overridden...
+	src, err = Generate(db, GenConfig{
+		Tables:     []string{"users"},
+		StructName: func(table string) string { return "Account" },
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "var AccountTable = azamat.Table[Account]{")
+
+	// When column types are numeric, or the Types have different casing...
+	db.MustExec(`CREATE TABLE prices (
+		id INTEGER PRIMARY KEY,
+		amount NUMERIC(10, 2) NOT NULL,
+		rate DECIMAL NOT NULL,
+		external_id UUID NOT NULL
+	)`)
+
+	src, err = Generate(db, GenConfig{
+		Tables: []string{"prices"},
+		Types:  map[string]string{"UUID": "github.com/google/uuid.UUID"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "Amount     string")
+	assert.Contains(t, string(src), "Rate       string")
+	assert.Contains(t, string(src), "ExternalID uuid.UUID")
+
+	// When two tables would generate the same struct
+	db.MustExec("CREATE TABLE todo (id INTEGER PRIMARY KEY)")
+	db.MustExec("CREATE TABLE todos (id INTEGER PRIMARY KEY)")
+
+	_, err = Generate(db, GenConfig{Tables: []string{"todo", "todos"}})
+	require.EqualError(t, err, "tables todo and todos would both generate the struct Todo")
+}
+
+func TestGeneratePostgres(t *testing.T) {
+	// Introspecting needs a Postgres db, so the schema is what it would return
+	g := generator{
+		config:   GenConfig{Package: "db", StructName: CamelCase, FieldName: CamelCase},
+		imports:  map[string]bool{},
+		structs:  map[string]string{},
+		postgres: true,
+	}
+
+	err := g.table("users", Schema{
+		Columns: []ColumnDef{
+			{Name: "id", Type: "bigint", AutoIncrement: true},
+			{Name: "email", Type: "character varying(255)"},
+			{Name: "created_at", Type: "timestamp with time zone"},
+		},
+		PrimaryKey: []string{"id"},
+	})
+	require.NoError(t, err)
+
+	src, err := g.source()
+	require.NoError(t, err)
+
+	expected := `// Code generated by azamat gen. DO NOT EDIT.
+
+package db
+
+import (
+	"time"
+
+	"github.com/NickDubelman/azamat"
+)
+
+type Users struct {
+	ID        int64     ` + "`" + `db:"id"` + "`" + `
+	Email     string    ` + "`" + `db:"email"` + "`" + `
+	CreatedAt time.Time ` + "`" + `db:"created_at"` + "`" + `
+}
+
+var UsersTable = azamat.Table[Users]{
+	Name: "users",
+	Columns: []string{
+		"id",
+		"email",
+		"created_at",
+	},
+	Postgres: true,
+	Schema: &azamat.Schema{
+		Columns: []azamat.ColumnDef{
+			{Name: "id", Type: "bigint", AutoIncrement: true},
+			{Name: "email", Type: "character varying(255)"},
+			{Name: "created_at", Type: "timestamp with time zone"},
+		},
+		PrimaryKey: []string{"id"},
+	},
+}
+`
+	assert.Equal(t, expected, string(src))
+}
+
+func TestNaming(t *testing.T) {
+	assert.Equal(t, "UserID", CamelCase("user_id"))
+	assert.Equal(t, "APIURL", CamelCase("api_url"))
+	assert.Equal(t, "TodoItems", CamelCase("todo_items"))
+	assert.Equal(t, "X2fa", CamelCase("2fa"))
+
+	assert.Equal(t, "Category", singular("Categories"))
+	assert.Equal(t, "Box", singular("Boxes"))
+	assert.Equal(t, "Todo", singular("Todos"))
+	assert.Equal(t, "Status", singular("Status"))
+	assert.Equal(t, "Address", singular("Address"))
+}
@@ -0,0 +1,664 @@
+package azamat
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// azamatImportPath is the import path of this package, as seen by generated code
+const azamatImportPath = "github.com/NickDubelman/azamat"
+
+// colsHeader marks the files that GenerateColumns generates, so they can be skipped
+const colsHeader = "// Code generated by azamat cols. DO NOT EDIT."
+
+// GenerateColumns reads the Table declarations in the Go package in dir, and
+// generates typed Columns for them. For a TodoTable variable of type Table[Todo], it
+// generates a TodoCols variable with a Column for each of the table's Columns, eg:
+// TodoCols.Title. The Go type of each Column comes from the field of Todo that the
+// column maps to, including the fields of embedded structs. Row types can be in other
+// packages, which are found the same way the go command finds them. The code is
+// gofmt'd
+func GenerateColumns(dir string) ([]byte, error) {
+	pkg, err := parsePackage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	g := colsGenerator{
+		pkg:      pkg,
+		imports:  map[string]string{},
+		packages: map[string]*goPackage{},
+	}
+	for _, table := range pkg.tables {
+		if err := g.table(table); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.source()
+}
+
+// goPackage is the parts of a parsed package that GenerateColumns cares about
+type goPackage struct {
+	name string
+	dir  string
+
+	// importPath is set for the packages that the generated code imports
+	importPath string
+
+	consts  map[string]string
+	structs map[string]goStruct
+	tables  []goTable
+}
+
+type goStruct struct {
+	typ  *ast.StructType
+	file *ast.File
+	pkg  *goPackage
+}
+
+type goTable struct {
+	variable string
+	rowType  string
+	name     string
+	columns  []string
+
+	// file is where the table is declared, which is needed to resolve its row type
+	file *ast.File
+}
+
+func parsePackage(dir string) (*goPackage, error) {
+	pkg, files, err := parseFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if err := pkg.collectTables(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return pkg, nil
+}
+
+// parseFiles parses the Go files of the package in dir and collects its types
+func parseFiles(dir string) (*goPackage, []*ast.File, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(filenames)
+
+	pkg := &goPackage{
+		dir:     dir,
+		consts:  map[string]string{},
+		structs: map[string]goStruct{},
+	}
+	fset := token.NewFileSet()
+
+	var files []*ast.File
+	for _, filename := range filenames {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if bytes.HasPrefix(src, []byte(colsHeader)) {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if pkg.name != "" && pkg.name != file.Name.Name {
+			return nil, nil, fmt.Errorf(
+				"%s has packages %s and %s", dir, pkg.name, file.Name.Name,
+			)
+		}
+		pkg.name = file.Name.Name
+
+		files = append(files, file)
+	}
+
+	if pkg.name == "" {
+		return nil, nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	// Consts and structs have to be collected before tables, since tables refer to them
+	for _, file := range files {
+		pkg.collectTypes(file)
+	}
+
+	return pkg, files, nil
+}
+
+func (pkg *goPackage) collectTypes(file *ast.File) {
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if typ, ok := spec.Type.(*ast.StructType); ok {
+					pkg.structs[spec.Name.Name] = goStruct{typ: typ, file: file, pkg: pkg}
+				}
+
+			case *ast.ValueSpec:
+				if decl.Tok != token.CONST {
+					continue
+				}
+
+				for i, name := range spec.Names {
+					if i >= len(spec.Values) {
+						break
+					}
+					if value, ok := stringLit(spec.Values[i]); ok {
+						pkg.consts[name.Name] = value
+					}
+				}
+			}
+		}
+	}
+}
+
+func (pkg *goPackage) collectTables(file *ast.File) error {
+	azamatName := importName(file, azamatImportPath)
+	if pkg.name == "azamat" {
+		azamatName = ""
+	}
+
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR {
+			continue
+		}
+
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			for i, name := range spec.Names {
+				if i >= len(spec.Values) {
+					break
+				}
+
+				lit, ok := spec.Values[i].(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+
+				rowType, ok := tableType(lit.Type, azamatName)
+				if !ok {
+					continue
+				}
+
+				table := goTable{variable: name.Name, rowType: rowType, file: file}
+				for _, elt := range lit.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+
+					field := types.ExprString(kv.Key)
+					switch field {
+					case "Name":
+						table.name, ok = pkg.stringValue(kv.Value)
+					case "Columns":
+						table.columns, ok = pkg.stringSlice(kv.Value)
+					}
+
+					if !ok {
+						return fmt.Errorf("%s.%s has to be a literal", name.Name, field)
+					}
+				}
+
+				pkg.tables = append(pkg.tables, table)
+			}
+		}
+	}
+
+	return nil
+}
+
+// tableType returns the row type of a Table[T] type expression
+func tableType(expr ast.Expr, azamatName string) (string, bool) {
+	index, ok := expr.(*ast.IndexExpr)
+	if !ok {
+		return "", false
+	}
+
+	switch x := index.X.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := x.X.(*ast.Ident)
+		if !ok || azamatName == "" || pkg.Name != azamatName || x.Sel.Name != "Table" {
+			return "", false
+		}
+	case *ast.Ident:
+		if azamatName != "" || x.Name != "Table" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return types.ExprString(index.Index), true
+}
+
+func (pkg *goPackage) stringValue(expr ast.Expr) (string, bool) {
+	if value, ok := stringLit(expr); ok {
+		return value, true
+	}
+
+	if ident, ok := expr.(*ast.Ident); ok {
+		value, ok := pkg.consts[ident.Name]
+		return value, ok
+	}
+
+	return "", false
+}
+
+func (pkg *goPackage) stringSlice(expr ast.Expr) ([]string, bool) {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]string, len(lit.Elts))
+	for i, elt := range lit.Elts {
+		if values[i], ok = pkg.stringValue(elt); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// importName returns the name that a file uses for an import, or "" if the file
+// doesn't import it
+func importName(file *ast.File, importPath string) string {
+	for _, spec := range file.Imports {
+		if p, _ := strconv.Unquote(spec.Path.Value); p != importPath {
+			continue
+		}
+
+		if spec.Name != nil {
+			return spec.Name.Name
+		}
+		return path.Base(importPath)
+	}
+	return ""
+}
+
+type colsGenerator struct {
+	pkg *goPackage
+
+	// imports maps import paths to the names they are imported as
+	imports map[string]string
+
+	// packages are the other packages that row types (or their embedded structs) are
+	// in, by import path
+	packages map[string]*goPackage
+
+	body bytes.Buffer
+}
+
+func (g *colsGenerator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// colsField is a field of a generated Cols struct
+type colsField struct {
+	name   string
+	goType string
+	column string
+}
+
+func (g *colsGenerator) table(table goTable) error {
+	column := "Column"
+	if g.pkg.name != "azamat" {
+		g.imports[azamatImportPath] = "azamat"
+		column = "azamat.Column"
+	}
+
+	fields, err := g.fields(table)
+	if err != nil {
+		return err
+	}
+	variable := strings.TrimSuffix(table.variable, "Table") + "Cols"
+
+	g.printf("// %s are the columns of %s\n", variable, table.variable)
+	g.printf("var %s = struct {\n", variable)
+	for _, field := range fields {
+		g.printf("%s %s[%s, %s]\n", field.name, column, table.rowType, field.goType)
+	}
+	g.printf("}{\n")
+	for _, field := range fields {
+		g.printf("%s: %q,\n", field.name, table.name+"."+field.column)
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+// fields matches the table's columns to the fields of its row struct, the same way
+// sqlx does: by db tag, or by the lowercased field name. The fields of embedded
+// structs are promoted, unless a field closer to the row has the same column
+func (g *colsGenerator) fields(table goTable) ([]colsField, error) {
+	byColumn := map[string]colsField{}
+
+	rowType, err := parser.ParseExpr(table.rowType)
+	if err != nil {
+		return nil, err
+	}
+
+	row, ok, err := g.lookupStruct(g.pkg, table.file, rowType)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		depths := map[string]int{}
+		visited := map[*ast.StructType]bool{}
+
+		var walk func(s goStruct, prefix string, depth int) error
+		walk = func(s goStruct, prefix string, depth int) error {
+			visited[s.typ] = true
+			defer delete(visited, s.typ)
+
+			for _, field := range s.typ.Fields.List {
+				column := ""
+				if field.Tag != nil {
+					tag, _ := strconv.Unquote(field.Tag.Value)
+					column, _, _ = strings.Cut(reflect.StructTag(tag).Get("db"), ",")
+				}
+
+				if len(field.Names) == 0 {
+					embedded, ok, err := g.lookupStruct(s.pkg, s.file, field.Type)
+					if err != nil {
+						return err
+					}
+					if !ok || visited[embedded.typ] {
+						continue
+					}
+
+					// Like sqlx, a tag on an embedded struct prefixes its columns
+					p := prefix
+					if column != "" {
+						p += column + "."
+					}
+					if err := walk(embedded, p, depth+1); err != nil {
+						return err
+					}
+					continue
+				}
+
+				for _, name := range field.Names {
+					if !name.IsExported() {
+						continue
+					}
+
+					c := column
+					if c == "" {
+						c = strings.ToLower(name.Name)
+					}
+					c = prefix + c
+
+					if d, ok := depths[c]; ok && d <= depth {
+						continue
+					}
+					depths[c] = depth
+
+					byColumn[c] = colsField{
+						name:   name.Name,
+						goType: g.fieldType(field.Type, s),
+						column: c,
+					}
+				}
+			}
+			return nil
+		}
+
+		if err := walk(row, "", 0); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := make([]colsField, len(table.columns))
+	for i, column := range table.columns {
+		field, ok := byColumn[column]
+		if !ok {
+			field = colsField{name: CamelCase(column), goType: "any", column: column}
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+// lookupStruct finds the struct that a type expression in a file refers to, which can
+// be in another package. The package is imported by the generated code
+func (g *colsGenerator) lookupStruct(
+	pkg *goPackage, file *ast.File, expr ast.Expr,
+) (goStruct, bool, error) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		s, ok := pkg.structs[expr.Name]
+		return s, ok, nil
+
+	case *ast.SelectorExpr:
+		name, ok := expr.X.(*ast.Ident)
+		if !ok {
+			return goStruct{}, false, nil
+		}
+
+		other, err := g.importPackage(pkg, file, name.Name)
+		if err != nil || other == nil {
+			return goStruct{}, false, err
+		}
+
+		s, ok := other.structs[expr.Sel.Name]
+		return s, ok, nil
+	}
+
+	return goStruct{}, false, nil
+}
+
+// importPackage parses the package that a file imports with the given name, and
+// imports it in the generated code. It returns nil if the file has no such import
+func (g *colsGenerator) importPackage(
+	pkg *goPackage, file *ast.File, name string,
+) (*goPackage, error) {
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if importName(file, importPath) != name {
+			continue
+		}
+
+		if _, ok := g.imports[importPath]; !ok {
+			g.imports[importPath] = name
+		}
+
+		if other, ok := g.packages[importPath]; ok {
+			return other, nil
+		}
+
+		dir, err := filepath.Abs(pkg.dir)
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := build.Import(importPath, dir, build.FindOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		other, _, err := parseFiles(found.Dir)
+		if err != nil {
+			return nil, err
+		}
+		other.importPath = importPath
+
+		g.packages[importPath] = other
+		return other, nil
+	}
+
+	return nil, nil
+}
+
+// fieldType returns the type of a field of a struct as the generated code has to
+// refer to it. Types declared in another package are qualified with its name, and a
+// type that the generated code can't refer to (eg: it isn't exported) is any
+func (g *colsGenerator) fieldType(expr ast.Expr, s goStruct) string {
+	if s.pkg.importPath != "" {
+		qualified, ok := qualifyType(expr, g.imports[s.pkg.importPath])
+		if !ok {
+			return "any"
+		}
+		expr = qualified
+	}
+	return g.goType(expr, s.file)
+}
+
+// qualifyType returns a copy of a type expression where the types that are declared
+// in its package are qualified with the package's name
+func qualifyType(expr ast.Expr, pkg string) (ast.Expr, bool) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if types.Universe.Lookup(expr.Name) != nil {
+			return expr, true
+		}
+		if !expr.IsExported() {
+			return nil, false
+		}
+		return &ast.SelectorExpr{X: ast.NewIdent(pkg), Sel: expr}, true
+
+	case *ast.SelectorExpr:
+		return expr, true
+
+	case *ast.StarExpr:
+		x, ok := qualifyType(expr.X, pkg)
+		return &ast.StarExpr{X: x}, ok
+
+	case *ast.ArrayType:
+		elt, ok := qualifyType(expr.Elt, pkg)
+		return &ast.ArrayType{Len: expr.Len, Elt: elt}, ok
+
+	case *ast.MapType:
+		key, ok := qualifyType(expr.Key, pkg)
+		if !ok {
+			return nil, false
+		}
+		value, ok := qualifyType(expr.Value, pkg)
+		return &ast.MapType{Key: key, Value: value}, ok
+
+	case *ast.IndexExpr:
+		x, ok := qualifyType(expr.X, pkg)
+		if !ok {
+			return nil, false
+		}
+		index, ok := qualifyType(expr.Index, pkg)
+		return &ast.IndexExpr{X: x, Index: index}, ok
+	}
+
+	return nil, false
+}
+
+// goType returns a field's type as a string, and imports the packages it refers to
+func (g *colsGenerator) goType(expr ast.Expr, file *ast.File) string {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		selector, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		pkg, ok := selector.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		for _, spec := range file.Imports {
+			p, _ := strconv.Unquote(spec.Path.Value)
+			if importName(file, p) == pkg.Name {
+				g.imports[p] = pkg.Name
+			}
+		}
+		return false
+	})
+
+	return types.ExprString(expr)
+}
+
+func (g *colsGenerator) source() ([]byte, error) {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "%s\n\n", colsHeader)
+	fmt.Fprintf(&src, "package %s\n\n", g.pkg.name)
+
+	// The standard library is imported first, like goimports does it
+	var std, others []string
+	for p := range g.imports {
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			others = append(others, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	if len(std)+len(others) > 0 {
+		fmt.Fprintf(&src, "import (\n")
+		for _, p := range std {
+			g.printImport(&src, p)
+		}
+		if len(std) > 0 && len(others) > 0 {
+			fmt.Fprintf(&src, "\n")
+		}
+		for _, p := range others {
+			g.printImport(&src, p)
+		}
+		fmt.Fprintf(&src, ")\n\n")
+	}
+
+	src.Write(g.body.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func (g *colsGenerator) printImport(src *bytes.Buffer, importPath string) {
+	if name := g.imports[importPath]; name != path.Base(importPath) {
+		fmt.Fprintf(src, "%s %q\n", name, importPath)
+	} else {
+		fmt.Fprintf(src, "%q\n", importPath)
+	}
+}
@@ -0,0 +1,240 @@
+package azamat
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateColumns(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name, src string) {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644)
+		require.NoError(t, err)
+	}
+
+	writeFile("todo.go", `package models
+
+import (
+	"time"
+
+	az "github.com/NickDubelman/azamat"
+)
+
+const todosTable = "todos"
+
+type Todo struct {
+	ID        int
+	Title     string
+	DueAt     *time.Time `+"`db:\"due_at\"`"+`
+	AuthorID  int        `+"`db:\"author_id,omitempty\"`"+`
+	internal  bool
+}
+
+var TodoTable = az.Table[Todo]{
+	Name:    todosTable,
+	Columns: []string{"id", "title", "due_at", "author_id", "legacy"},
+}
+`)
+
+	writeFile("user.go", `package models
+
+import "github.com/NickDubelman/azamat"
+
+type User struct {
+	ID   int
+	Name string
+}
+
+var Users = azamat.Table[User]{Name: "users", Columns: []string{"id", "name"}}
+
+// Not a Table, so it is ignored
+var notATable = struct{ Name string }{Name: "nope"}
+`)
+
+	// Tests and previously generated code are ignored
+	writeFile("todo_test.go", "package models_test\n")
+	writeFile("azamat_cols.go", colsHeader+"\n\npackage models\n\nvar TodoCols = 1\n")
+
+	src, err := GenerateColumns(dir)
+	require.NoError(t, err)
+
+	expected := `// Code generated by azamat cols. DO NOT EDIT.
+
+package models
+
+import (
+	"time"
+
+	"github.com/NickDubelman/azamat"
+)
+
+// TodoCols are the columns of TodoTable
+var TodoCols = struct {
+	ID       azamat.Column[Todo, int]
+	Title    azamat.Column[Todo, string]
+	DueAt    azamat.Column[Todo, *time.Time]
+	AuthorID azamat.Column[Todo, int]
+	Legacy   azamat.Column[Todo, any]
+}{
+	ID:       "todos.id",
+	Title:    "todos.title",
+	DueAt:    "todos.due_at",
+	AuthorID: "todos.author_id",
+	Legacy:   "todos.legacy",
+}
+
+// UsersCols are the columns of Users
+var UsersCols = struct {
+	ID   azamat.Column[User, int]
+	Name azamat.Column[User, string]
+}{
+	ID:   "users.id",
+	Name: "users.name",
+}
+`
+	require.Equal(t, expected, string(src))
+
+	// When a Table's Columns aren't a literal
+	writeFile("user.go", `package models
+
+import "github.com/NickDubelman/azamat"
+
+type User struct{}
+
+var userColumns = []string{"id"}
+
+var UserTable = azamat.Table[User]{Name: "users", Columns: userColumns}
+`)
+	_, err = GenerateColumns(dir)
+	require.EqualError(t, err, "UserTable.Columns has to be a literal")
+
+	// When the dir has no Go files
+	_, err = GenerateColumns(t.TempDir())
+	require.Error(t, err)
+}
+
+func TestGenerateColumnsEmbedded(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is needed to compile the generated code")
+	}
+
+	// The packages go in testdata so that they are part of this module, which lets the
+	// generated code be compiled without a go.mod of its own
+	require.NoError(t, os.MkdirAll("testdata", 0o755))
+	root, err := os.MkdirTemp("testdata", "cols")
+	require.NoError(t, err)
+	defer os.Remove("testdata")
+	defer os.RemoveAll(root)
+
+	writeFile := func(name, src string) {
+		name = filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte(src), 0o644))
+	}
+
+	modelsPath := azamatImportPath + "/" + filepath.ToSlash(root) + "/models"
+
+	writeFile("models/models.go", `package models
+
+import "time"
+
+type Status string
+
+type kind int
+
+type Base struct {
+	ID     int64
+	Status Status
+	Kind   kind
+}
+
+type Timestamps struct {
+	CreatedAt time.Time `+"`db:\"created_at\"`"+`
+}
+
+type Todo struct {
+	Base
+	*Timestamps
+	Title  string
+	Status string `+"`db:\"status\"`"+`
+}
+`)
+
+	writeFile("app/app.go", `package app
+
+import (
+	"github.com/NickDubelman/azamat"
+	m "`+modelsPath+`"
+)
+
+type Note struct {
+	m.Base
+	Body string
+}
+
+var TodoTable = azamat.Table[m.Todo]{
+	Name:    "todos",
+	Columns: []string{"id", "status", "kind", "created_at", "title"},
+}
+
+var NoteTable = azamat.Table[Note]{
+	Name:    "notes",
+	Columns: []string{"id", "status", "body"},
+}
+`)
+
+	// When a row is in another package, or has embedded structs
+	src, err := GenerateColumns(filepath.Join(root, "app"))
+	require.NoError(t, err)
+
+	expected := `// Code generated by azamat cols. DO NOT EDIT.
+
+package app
+
+import (
+	"time"
+
+	"github.com/NickDubelman/azamat"
+	m "` + modelsPath + `"
+)
+
+// TodoCols are the columns of TodoTable
+var TodoCols = struct {
+	ID        azamat.Column[m.Todo, int64]
+	Status    azamat.Column[m.Todo, string]
+	Kind      azamat.Column[m.Todo, any]
+	CreatedAt azamat.Column[m.Todo, time.Time]
+	Title     azamat.Column[m.Todo, string]
+}{
+	ID:        "todos.id",
+	Status:    "todos.status",
+	Kind:      "todos.kind",
+	CreatedAt: "todos.created_at",
+	Title:     "todos.title",
+}
+
+// NoteCols are the columns of NoteTable
+var NoteCols = struct {
+	ID     azamat.Column[Note, int64]
+	Status azamat.Column[Note, m.Status]
+	Body   azamat.Column[Note, string]
+}{
+	ID:     "notes.id",
+	Status: "notes.status",
+	Body:   "notes.body",
+}
+`
+	require.Equal(t, expected, string(src))
+
+	// The generated code compiles
+	writeFile("app/azamat_cols.go", string(src))
+
+	build := exec.Command("go", "build", "./"+filepath.ToSlash(root)+"/app")
+	output, err := build.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
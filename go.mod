@@ -4,14 +4,16 @@ go 1.18
 
 require (
 	github.com/Masterminds/squirrel v1.5.2
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jmoiron/sqlx v1.3.4
+	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/stretchr/testify v1.2.2
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
 	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
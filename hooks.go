@@ -0,0 +1,158 @@
+package azamat
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lann/builder"
+)
+
+// hook lets a Table attach behavior to the builders it returns. Hooks run right
+// before a statement is turned into SQL, so they can see everything the caller added
+// to the builder
+type hook[B any] func(ctx context.Context, b B) (B, error)
+
+func applyHooks[B any](ctx context.Context, b B, hooks []hook[B]) (B, error) {
+	for _, h := range hooks {
+		var err error
+		if b, err = h(ctx, b); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// wrapRun lets a Table take over running the statements it builds, for behavior that
+// needs to do more than change the SQL (eg: recording changes in an audit log). It is
+// given the built statement and a function that executes it
+type wrapRun[B any] func(
+	ctx context.Context,
+	runner Runner,
+	built B,
+	exec func(Runner) (sql.Result, error),
+) (sql.Result, error)
+
+// insertColumns returns the columns that have been added to an insert statement
+func insertColumns(b sq.InsertBuilder) []string {
+	cols, _ := builder.Get(b, "Columns")
+	columns, _ := cols.([]string)
+	return columns
+}
+
+// insertValues returns the rows of values that have been added to an insert statement
+func insertValues(b sq.InsertBuilder) [][]interface{} {
+	vals, _ := builder.Get(b, "Values")
+	values, _ := vals.([][]interface{})
+	return values
+}
+
+// insertSuffix returns the SQL of the suffixes of an insert statement, eg: its ON
+// CONFLICT clause
+func insertSuffix(b sq.InsertBuilder) (string, error) {
+	suffixes, _ := builder.Get(b, "Suffixes")
+	parts, _ := suffixes.([]sq.Sqlizer)
+
+	sqls := make([]string, len(parts))
+	for i, part := range parts {
+		sql, _, err := part.ToSql()
+		if err != nil {
+			return "", err
+		}
+		sqls[i] = sql
+	}
+	return strings.Join(sqls, " "), nil
+}
+
+// insertsSelect is true for an INSERT ... SELECT statement
+func insertsSelect(b sq.InsertBuilder) bool {
+	selected, _ := builder.Get(b, "Select")
+	return selected != nil
+}
+
+// addInsertColumn adds a column to an insert statement, using the same value for every
+// row. If the column was already provided by the caller, the statement is left as is
+func addInsertColumn(
+	b sq.InsertBuilder, column string, value interface{},
+) sq.InsertBuilder {
+	columns := insertColumns(b)
+	values := insertValues(b)
+	if len(values) == 0 || contains(columns, column) {
+		return b
+	}
+
+	newValues := make([][]interface{}, len(values))
+	for i, row := range values {
+		newValues[i] = append(row[:len(row):len(row)], value)
+	}
+
+	b = b.Columns(column)
+	return builder.Set(b, "Values", newValues).(sq.InsertBuilder)
+}
+
+// updateColumns returns the columns that are being set by an update statement
+func updateColumns(b sq.UpdateBuilder) (columns []string) {
+	clauses, _ := builder.Get(b, "SetClauses")
+
+	// squirrel doesn't export its set clauses, so we have to use reflection to read
+	// which column each of them is for
+	v := reflect.ValueOf(clauses)
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		columns = append(columns, v.Index(i).FieldByName("column").String())
+	}
+	return
+}
+
+// deleteToUpdate turns a delete statement into an update statement on the given table
+// that affects the same rows. The caller is responsible for adding the SET clauses
+func deleteToUpdate(d sq.DeleteBuilder, table string) sq.UpdateBuilder {
+	u := sq.Update(table)
+
+	for _, field := range []string{"PlaceholderFormat", "RunWith", "Limit", "Offset"} {
+		if v, ok := builder.Get(d, field); ok {
+			u = builder.Set(u, field, v).(sq.UpdateBuilder)
+		}
+	}
+
+	for _, field := range []string{"Prefixes", "WhereParts", "OrderBys", "Suffixes"} {
+		if v, ok := builder.Get(d, field); ok {
+			u = builder.Extend(u, field, v).(sq.UpdateBuilder)
+		}
+	}
+
+	return u
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// errPredicate is a predicate that fails to build. It is used to make sure a query
+// that can't be built safely is never run
+type errPredicate struct {
+	err error
+}
+
+func (p errPredicate) ToSql() (string, []interface{}, error) {
+	return "", nil, p.err
+}
+
+// errRow is returned by QueryRow when a hook fails, so the error surfaces on Scan
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...interface{}) error {
+	return r.err
+}
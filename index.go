@@ -0,0 +1,77 @@
+package azamat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// indexes returns the indexes that the table declares, both in its Schema and in its
+// Indexes
+func (t Table[T]) indexes() []IndexDef {
+	var indexes []IndexDef
+	if t.Schema != nil {
+		indexes = append(indexes, t.Schema.Indexes...)
+	}
+	return append(indexes, t.Indexes...)
+}
+
+// CreateIndexes creates the table's indexes that don't exist yet
+func (t Table[T]) CreateIndexes(runner Runner) error {
+	return t.createIndexes(runner, true)
+}
+
+func (t Table[T]) createIndexes(runner Runner, ifNotExists bool) error {
+	dialect := DialectOf(runner)
+	if dialect == "" {
+		dialect = t.dialect()
+	}
+
+	// The columns are only known when the table has a Schema
+	var columns map[string]bool
+	if t.Schema != nil {
+		columns = map[string]bool{}
+		for _, column := range t.Schema.Columns {
+			columns[strings.ToLower(column.Name)] = true
+		}
+	}
+
+	indexes := t.indexes()
+	for _, index := range indexes {
+		if err := index.validate(columns); err != nil {
+			return fmt.Errorf("invalid index for %s: %w", t.Name, err)
+		}
+	}
+
+	// MySQL doesn't support CREATE INDEX IF NOT EXISTS, so skip the indexes that
+	// already exist instead
+	if ifNotExists && dialect == DialectMySQL {
+		var existing []string
+		err := runner.Select(&existing, `
+			SELECT DISTINCT index_name FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ?
+		`, t.Name)
+		if err != nil {
+			return err
+		}
+
+		var missing []IndexDef
+		for _, index := range indexes {
+			if !containsFold(existing, index.Name) {
+				missing = append(missing, index)
+			}
+		}
+		indexes, ifNotExists = missing, false
+	}
+
+	statements, err := createIndexSQL(t.Name, indexes, dialect, ifNotExists)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		if _, err := runner.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableIndexes(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type User struct {
+		ID      int
+		Email   string
+		OrgID   int `db:"org_id"`
+		Deleted bool
+	}
+
+	UserTable := Table[User]{
+		Name:    "users",
+		Columns: []string{"id", "email", "org_id", "deleted"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL,
+			org_id INTEGER NOT NULL,
+			deleted BOOLEAN NOT NULL DEFAULT false
+		`,
+		Indexes: []IndexDef{
+			{Name: "users_org", Columns: []string{"org_id", "id"}},
+			{
+				Name:        "users_email",
+				Expressions: []string{"lower(email)"},
+				Unique:      true,
+				Where:       "NOT deleted",
+			},
+		},
+	}
+
+	require.NoError(t, UserTable.Create(db))
+
+	indexes := func() (names []string) {
+		db.Select(&names, `
+			SELECT name FROM sqlite_master
+			WHERE type = 'index' AND tbl_name = 'users'
+			ORDER BY name
+		`)
+		return
+	}
+	assert.Equal(t, []string{"users_email", "users_org"}, indexes())
+
+	// (the unique index only covers rows that aren't deleted)
+	_, err := UserTable.
+		Insert().
+		Columns("email", "org_id", "deleted").
+		Values("borat@aol.com", 1, true).
+		Values("Borat@aol.com", 1, false).
+		Run(db)
+	require.NoError(t, err)
+
+	_, err = UserTable.Insert().Columns("email", "org_id").Values("BORAT@aol.com", 1).Run(db)
+	require.Error(t, err)
+
+	// When the indexes already exist...
+	require.NoError(t, UserTable.CreateIfNotExists(db))
+	require.NoError(t, UserTable.CreateIndexes(db))
+
+	// When an index is added to a table that already exists...
+	UserTable.Indexes = append(UserTable.Indexes, IndexDef{
+		Name:    "users_deleted",
+		Columns: []string{"deleted"},
+	})
+	require.NoError(t, UserTable.CreateIfNotExists(db))
+	assert.Equal(t, []string{"users_deleted", "users_email", "users_org"}, indexes())
+
+	// (the table matches its declared schema)
+	diff, err := Diff(db, UserTable)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+
+	// When an index is invalid...
+	UserTable.Indexes = []IndexDef{{Name: "users_nothing"}}
+	require.Error(t, UserTable.CreateIndexes(db))
+}
+
+func TestIndexCreateSQL(t *testing.T) {
+	index := IndexDef{
+		Name:        "users_email",
+		Columns:     []string{"org_id"},
+		Expressions: []string{"lower(email)"},
+		Unique:      true,
+		Where:       "deleted_at IS NULL",
+	}
+
+	sql, err := index.createSQL("users", DialectPostgres, true)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users (org_id, (lower(email))) "+
+			"WHERE deleted_at IS NULL",
+		sql,
+	)
+
+	// When the dialect doesn't support partial indexes...
+	_, err = index.createSQL("users", DialectMySQL, false)
+	require.Error(t, err)
+
+	index.Where = ""
+	sql, err = index.createSQL("users", DialectMySQL, false)
+	require.NoError(t, err)
+	assert.Equal(
+		t, "CREATE UNIQUE INDEX users_email ON users (org_id, (lower(email)))", sql,
+	)
+}
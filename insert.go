@@ -1,6 +1,7 @@
 package azamat
 
 import (
+	"context"
 	"database/sql"
 
 	sq "github.com/Masterminds/squirrel"
@@ -8,15 +9,98 @@ import (
 
 type InsertBuilder struct {
 	sq.InsertBuilder
+	hooks   []hook[sq.InsertBuilder]
+	wrapRun wrapRun[sq.InsertBuilder]
 }
 
 func Insert(into string) InsertBuilder {
-	return InsertBuilder{sq.Insert(into)}
+	return InsertBuilder{InsertBuilder: sq.Insert(into)}
 }
 
 // Run executes the insert and returns the last inserted ID
 func (b InsertBuilder) Run(runner Runner) (sql.Result, error) {
-	return b.RunWith(runner).Exec()
+	return b.RunContext(context.Background(), runner)
+}
+
+// RunContext is like Run but passes the context to the database and to any behavior
+// that the statement's Table adds (eg: tenant isolation)
+func (b InsertBuilder) RunContext(
+	ctx context.Context, runner Runner,
+) (sql.Result, error) {
+	if b.wrapRun == nil {
+		return b.RunWith(withContext(runner)).ExecContext(ctx)
+	}
+
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := func(runner Runner) (sql.Result, error) {
+		return built.RunWith(withContext(runner)).ExecContext(ctx)
+	}
+	return b.wrapRun(ctx, runner, built, exec)
+}
+
+func (b InsertBuilder) build(ctx context.Context) (sq.InsertBuilder, error) {
+	return applyHooks(ctx, b.InsertBuilder, b.hooks)
+}
+
+// ToSql builds the query into a SQL string and bound args
+func (b InsertBuilder) ToSql() (string, []interface{}, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return "", nil, err
+	}
+	return built.ToSql()
+}
+
+func (b InsertBuilder) Exec() (sql.Result, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return built.Exec()
+}
+
+func (b InsertBuilder) Query() (*sql.Rows, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return built.Query()
+}
+
+func (b InsertBuilder) QueryRow() sq.RowScanner {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return errRow{err}
+	}
+	return built.QueryRow()
+}
+
+func (b InsertBuilder) ExecContext(ctx context.Context) (sql.Result, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.ExecContext(ctx)
+}
+
+func (b InsertBuilder) QueryContext(ctx context.Context) (*sql.Rows, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.QueryContext(ctx)
+}
+
+func (b InsertBuilder) QueryRowContext(ctx context.Context) sq.RowScanner {
+	built, err := b.build(ctx)
+	if err != nil {
+		return errRow{err}
+	}
+	return built.QueryRowContext(ctx)
 }
 
 func (b InsertBuilder) PlaceholderFormat(f sq.PlaceholderFormat) InsertBuilder {
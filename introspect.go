@@ -0,0 +1,478 @@
+package azamat
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Introspect reads the schema of a table from the database that the runner is
+// connected to. Check constraints aren't introspected, since SQLite doesn't expose them
+func Introspect(runner Runner, table string) (Schema, error) {
+	var schema Schema
+	var err error
+
+	switch DialectOf(runner) {
+	case DialectSQLite:
+		schema, err = introspectSQLite(runner, table)
+	case DialectPostgres:
+		schema, err = introspectPostgres(runner, table)
+	case DialectMySQL:
+		schema, err = introspectMySQL(runner, table)
+	default:
+		return schema, fmt.Errorf("can't introspect an unknown dialect")
+	}
+
+	if err == nil && len(schema.Columns) == 0 {
+		err = fmt.Errorf("table %s does not exist", table)
+	}
+	return schema, err
+}
+
+// TableNames returns the names of the tables in the database that the runner is
+// connected to, sorted by name
+func TableNames(runner Runner) ([]string, error) {
+	var query string
+
+	switch DialectOf(runner) {
+	case DialectSQLite:
+		query = `
+			SELECT name FROM sqlite_master
+			WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		`
+	case DialectPostgres:
+		query = `
+			SELECT table_name FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+		`
+	case DialectMySQL:
+		query = `
+			SELECT table_name FROM information_schema.tables
+			WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'
+		`
+	default:
+		return nil, fmt.Errorf("can't introspect an unknown dialect")
+	}
+
+	var names []string
+	if err := runner.Select(&names, query); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func introspectSQLite(runner Runner, table string) (Schema, error) {
+	var schema Schema
+
+	var columns []struct {
+		CID     int
+		Name    string
+		Type    string
+		NotNull bool
+		Default sql.NullString `db:"dflt_value"`
+		PK      int
+	}
+
+	query := "SELECT * FROM pragma_table_info(?)"
+	if err := runner.Select(&columns, query, table); err != nil {
+		return schema, err
+	}
+
+	pk := map[int]string{}
+	for _, c := range columns {
+		schema.Columns = append(schema.Columns, ColumnDef{
+			Name:     c.Name,
+			Type:     c.Type,
+			Nullable: !c.NotNull && c.PK == 0,
+			Default:  c.Default.String,
+		})
+
+		if c.PK > 0 {
+			pk[c.PK] = c.Name
+		}
+	}
+
+	for i := 1; i <= len(pk); i++ {
+		schema.PrimaryKey = append(schema.PrimaryKey, pk[i])
+	}
+
+	// AUTOINCREMENT is only visible in the statement that created the table
+	var createTable string
+	query = "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?"
+	if err := runner.Get(&createTable, query, table); err != nil && err != sql.ErrNoRows {
+		return schema, err
+	}
+
+	if len(schema.PrimaryKey) == 1 && autoincrement.MatchString(createTable) {
+		for i, column := range schema.Columns {
+			if column.Name == schema.PrimaryKey[0] {
+				schema.Columns[i].AutoIncrement = true
+			}
+		}
+	}
+
+	var indexes []struct {
+		Seq     int
+		Name    string
+		Unique  bool
+		Origin  string
+		Partial bool
+	}
+
+	query = "SELECT * FROM pragma_index_list(?)"
+	if err := runner.Select(&indexes, query, table); err != nil {
+		return schema, err
+	}
+
+	// Indexes are listed newest first
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Seq > indexes[j].Seq })
+
+	for _, index := range indexes {
+		var columns []sql.NullString
+		query := "SELECT name FROM pragma_index_info(?) ORDER BY seqno"
+		if err := runner.Select(&columns, query, index.Name); err != nil {
+			return schema, err
+		}
+
+		// Expressions don't have a name
+		var names []string
+		for _, c := range columns {
+			if c.Valid {
+				names = append(names, c.String)
+			}
+		}
+
+		// origin is "c" for CREATE INDEX, "u" for UNIQUE constraints, and "pk" for
+		// primary keys
+		switch index.Origin {
+		case "c":
+			schema.Indexes = append(schema.Indexes, IndexDef{
+				Name:    index.Name,
+				Columns: names,
+				Unique:  index.Unique,
+			})
+		case "u":
+			schema.Uniques = append(schema.Uniques, UniqueDef{Columns: names})
+		}
+	}
+
+	var foreignKeys []struct {
+		ID       int
+		Seq      int
+		Table    string
+		From     string
+		To       sql.NullString
+		OnUpdate string `db:"on_update"`
+		OnDelete string `db:"on_delete"`
+		Match    string
+	}
+
+	query = "SELECT * FROM pragma_foreign_key_list(?) ORDER BY id, seq"
+	if err := runner.Select(&foreignKeys, query, table); err != nil {
+		return schema, err
+	}
+
+	byID := map[int]*ForeignKeyDef{}
+	var order []int
+	for _, fk := range foreignKeys {
+		def, ok := byID[fk.ID]
+		if !ok {
+			def = &ForeignKeyDef{
+				RefTable: fk.Table,
+				OnDelete: foreignKeyAction(fk.OnDelete),
+				OnUpdate: foreignKeyAction(fk.OnUpdate),
+			}
+			byID[fk.ID] = def
+			order = append(order, fk.ID)
+		}
+
+		def.Columns = append(def.Columns, fk.From)
+		def.RefColumns = append(def.RefColumns, fk.To.String)
+	}
+
+	for _, id := range order {
+		schema.ForeignKeys = append(schema.ForeignKeys, *byID[id])
+	}
+
+	return schema, nil
+}
+
+var autoincrement = regexp.MustCompile(`(?i)PRIMARY\s+KEY\s+AUTOINCREMENT`)
+
+// keyColumn is a column of a primary key, unique, or foreign key constraint
+type keyColumn struct {
+	Constraint string         `db:"constraint_name"`
+	Type       string         `db:"constraint_type"`
+	Column     string         `db:"column_name"`
+	RefTable   sql.NullString `db:"ref_table"`
+	RefColumn  sql.NullString `db:"ref_column"`
+	OnDelete   sql.NullString `db:"on_delete"`
+	OnUpdate   sql.NullString `db:"on_update"`
+}
+
+type infoColumn struct {
+	Name     string         `db:"column_name"`
+	Type     string         `db:"data_type"`
+	Nullable string         `db:"is_nullable"`
+	Default  sql.NullString `db:"column_default"`
+	Extra    string         `db:"extra"`
+}
+
+func (s *Schema) addInfoColumns(columns []infoColumn) {
+	for _, c := range columns {
+		column := ColumnDef{
+			Name:     c.Name,
+			Type:     c.Type,
+			Nullable: c.Nullable == "YES",
+			Default:  c.Default.String,
+		}
+
+		// Postgres implements SERIAL with a sequence, while MySQL calls out
+		// AUTO_INCREMENT in the extra column
+		if strings.HasPrefix(column.Default, "nextval(") ||
+			strings.Contains(strings.ToLower(c.Extra), "auto_increment") {
+			column.AutoIncrement = true
+			column.Default = ""
+		}
+
+		s.Columns = append(s.Columns, column)
+	}
+}
+
+// addKeyColumns adds the constraints described by the key columns, which have to be
+// sorted by constraint and then by position. It returns the names of the constraints
+func (s *Schema) addKeyColumns(keys []keyColumn) map[string]bool {
+	names := map[string]bool{}
+	var fk *ForeignKeyDef
+
+	for i, key := range keys {
+		names[key.Constraint] = true
+		first := i == 0 || keys[i-1].Constraint != key.Constraint
+
+		switch key.Type {
+		case "PRIMARY KEY":
+			s.PrimaryKey = append(s.PrimaryKey, key.Column)
+
+		case "UNIQUE":
+			if first {
+				s.Uniques = append(s.Uniques, UniqueDef{Name: key.Constraint})
+			}
+			u := &s.Uniques[len(s.Uniques)-1]
+			u.Columns = append(u.Columns, key.Column)
+
+		case "FOREIGN KEY":
+			if first {
+				s.ForeignKeys = append(s.ForeignKeys, ForeignKeyDef{
+					Name:     key.Constraint,
+					RefTable: key.RefTable.String,
+					OnDelete: foreignKeyAction(key.OnDelete.String),
+					OnUpdate: foreignKeyAction(key.OnUpdate.String),
+				})
+				fk = &s.ForeignKeys[len(s.ForeignKeys)-1]
+			}
+			fk.Columns = append(fk.Columns, key.Column)
+			fk.RefColumns = append(fk.RefColumns, key.RefColumn.String)
+		}
+	}
+
+	return names
+}
+
+// pgAction turns one of pg_constraint's foreign key action codes into its SQL
+func pgAction(column string) string {
+	return fmt.Sprintf(`CASE %s
+		WHEN 'r' THEN 'RESTRICT'
+		WHEN 'c' THEN 'CASCADE'
+		WHEN 'n' THEN 'SET NULL'
+		WHEN 'd' THEN 'SET DEFAULT'
+		WHEN 'a' THEN 'NO ACTION'
+	END`, column)
+}
+
+func introspectPostgres(runner Runner, table string) (Schema, error) {
+	var schema Schema
+
+	var columns []infoColumn
+	err := runner.Select(&columns, `
+		SELECT column_name, data_type, is_nullable, column_default, '' AS extra
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return schema, err
+	}
+	schema.addInfoColumns(columns)
+
+	// Constraint names are only unique per table on Postgres, so the constraints are
+	// read from pg_constraint, which ties each of them to its table
+	var keys []keyColumn
+	err = runner.Select(&keys, `
+		SELECT
+			c.conname AS constraint_name,
+			CASE c.contype
+				WHEN 'p' THEN 'PRIMARY KEY'
+				WHEN 'u' THEN 'UNIQUE'
+				ELSE 'FOREIGN KEY'
+			END AS constraint_type,
+			a.attname AS column_name,
+			ref.relname AS ref_table,
+			ra.attname AS ref_column,
+			`+pgAction("c.confdeltype")+` AS on_delete,
+			`+pgAction("c.confupdtype")+` AS on_update
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(c.conkey) WITH ORDINALITY AS k(attnum, position)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		LEFT JOIN pg_class ref ON ref.oid = c.confrelid
+		LEFT JOIN pg_attribute ra
+			ON ra.attrelid = c.confrelid AND ra.attnum = c.confkey[k.position]
+		WHERE n.nspname = current_schema() AND t.relname = $1
+			AND c.contype IN ('p', 'u', 'f')
+		ORDER BY c.conname, k.position
+	`, table)
+	if err != nil {
+		return schema, err
+	}
+	constraints := schema.addKeyColumns(keys)
+
+	var indexes []struct {
+		Name string `db:"indexname"`
+		Def  string `db:"indexdef"`
+	}
+	err = runner.Select(&indexes, `
+		SELECT indexname, indexdef FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = $1
+		ORDER BY indexname
+	`, table)
+	if err != nil {
+		return schema, err
+	}
+
+	for _, index := range indexes {
+		// Skip the indexes that back primary key and unique constraints
+		if constraints[index.Name] {
+			continue
+		}
+
+		// Expression indexes don't match, so only their names are known
+		def := IndexDef{Name: index.Name}
+		if m := indexDef.FindStringSubmatch(index.Def); m != nil {
+			def.Columns = splitNames(m[2])
+			def.Unique = m[1] != ""
+		}
+
+		schema.Indexes = append(schema.Indexes, def)
+	}
+
+	return schema, nil
+}
+
+// indexDef matches the definitions of column indexes in pg_indexes, eg:
+// CREATE UNIQUE INDEX todos_title ON public.todos USING btree (title)
+var indexDef = regexp.MustCompile(
+	`(?i)^CREATE (UNIQUE )?INDEX .* USING \w+ \(([^()]*)\)`,
+)
+
+func introspectMySQL(runner Runner, table string) (Schema, error) {
+	var schema Schema
+
+	var columns []infoColumn
+	err := runner.Select(&columns, `
+		SELECT
+			column_name AS column_name,
+			column_type AS data_type,
+			is_nullable AS is_nullable,
+			column_default AS column_default,
+			extra AS extra
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE() AND table_name = ?
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return schema, err
+	}
+	schema.addInfoColumns(columns)
+
+	var keys []keyColumn
+	err = runner.Select(&keys, `
+		SELECT
+			tc.constraint_name AS constraint_name,
+			tc.constraint_type AS constraint_type,
+			kcu.column_name AS column_name,
+			kcu.referenced_table_name AS ref_table,
+			kcu.referenced_column_name AS ref_column,
+			rc.delete_rule AS on_delete,
+			rc.update_rule AS on_update
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = tc.constraint_schema
+			AND kcu.table_name = tc.table_name
+			AND kcu.constraint_name = tc.constraint_name
+		LEFT JOIN information_schema.referential_constraints rc
+			ON rc.constraint_schema = tc.constraint_schema
+			AND rc.table_name = tc.table_name
+			AND rc.constraint_name = tc.constraint_name
+		WHERE tc.table_schema = DATABASE() AND tc.table_name = ?
+		ORDER BY tc.constraint_name, kcu.ordinal_position
+	`, table)
+	if err != nil {
+		return schema, err
+	}
+	constraints := schema.addKeyColumns(keys)
+
+	var indexes []struct {
+		Name      string         `db:"index_name"`
+		NonUnique bool           `db:"non_unique"`
+		Column    sql.NullString `db:"column_name"` // null for expressions
+	}
+	err = runner.Select(&indexes, `
+		SELECT
+			index_name AS index_name,
+			non_unique AS non_unique,
+			column_name AS column_name
+		FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = ?
+		ORDER BY index_name, seq_in_index
+	`, table)
+	if err != nil {
+		return schema, err
+	}
+
+	for i, index := range indexes {
+		// Skip the indexes that back constraints. MySQL also creates an index for
+		// every foreign key, which shares the constraint's name
+		if index.Name == "PRIMARY" || constraints[index.Name] {
+			continue
+		}
+
+		if i == 0 || indexes[i-1].Name != index.Name {
+			schema.Indexes = append(schema.Indexes, IndexDef{
+				Name:   index.Name,
+				Unique: !index.NonUnique,
+			})
+		}
+
+		def := &schema.Indexes[len(schema.Indexes)-1]
+		if index.Column.Valid {
+			def.Columns = append(def.Columns, index.Column.String)
+		}
+	}
+
+	return schema, nil
+}
+
+// foreignKeyAction normalizes a referential action. NO ACTION is the default, so it
+// is normalized to an empty string
+func foreignKeyAction(action string) string {
+	action = strings.ToUpper(strings.Join(strings.Fields(action), " "))
+	if action == "NO ACTION" {
+		return ""
+	}
+	return action
+}
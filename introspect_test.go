@@ -0,0 +1,111 @@
+package azamat
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospect(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	db.MustExec("CREATE TABLE users (id INTEGER PRIMARY KEY)")
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		author_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		completed BOOLEAN DEFAULT false,
+		UNIQUE (author_id, title)
+	)`)
+	db.MustExec("CREATE INDEX todos_completed ON todos (completed)")
+
+	names, err := TableNames(db)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"todos", "users"}, names)
+
+	schema, err := Introspect(db, "todos")
+	require.NoError(t, err)
+
+	expectedColumns := []ColumnDef{
+		{Name: "id", Type: "INTEGER", AutoIncrement: true},
+		{Name: "title", Type: "TEXT"},
+		{Name: "author_id", Type: "INTEGER", Nullable: true},
+		{Name: "completed", Type: "BOOLEAN", Nullable: true, Default: "false"},
+	}
+	assert.Equal(t, expectedColumns, schema.Columns)
+	assert.Equal(t, []string{"id"}, schema.PrimaryKey)
+
+	expectedUniques := []UniqueDef{{Columns: []string{"author_id", "title"}}}
+	assert.Equal(t, expectedUniques, schema.Uniques)
+
+	expectedIndexes := []IndexDef{
+		{Name: "todos_completed", Columns: []string{"completed"}},
+	}
+	assert.Equal(t, expectedIndexes, schema.Indexes)
+
+	expectedForeignKeys := []ForeignKeyDef{
+		{
+			Columns:    []string{"author_id"},
+			RefTable:   "users",
+			RefColumns: []string{"id"},
+			OnDelete:   "CASCADE",
+		},
+	}
+	assert.Equal(t, expectedForeignKeys, schema.ForeignKeys)
+
+	// When the table doesn't exist...
+	_, err = Introspect(db, "nope")
+	require.Error(t, err)
+}
+
+func TestIntrospectSharedConstraintName(t *testing.T) {
+	sqlite, _ := sqlx.Open("sqlite3", ":memory:")
+	sqlite.SetMaxOpenConns(1)
+	dbs := map[string]*sqlx.DB{"sqlite": sqlite}
+
+	// Constraint names are only unique per table on Postgres, so this needs a real db
+	if dsn := os.Getenv("AZAMAT_POSTGRES_DSN"); dsn != "" {
+		postgres, err := sqlx.Open("postgres", dsn)
+		require.NoError(t, err)
+		dbs["postgres"] = postgres
+	}
+
+	for name, db := range dbs {
+		t.Run(name, func(t *testing.T) {
+			tables := []string{"introspect_todos", "introspect_notes", "introspect_users"}
+			drop := func() {
+				for _, table := range tables {
+					db.MustExec("DROP TABLE IF EXISTS " + table)
+				}
+			}
+			drop()
+			defer drop()
+
+			db.MustExec("CREATE TABLE introspect_users (id INTEGER PRIMARY KEY)")
+			for _, table := range tables[:2] {
+				db.MustExec(`CREATE TABLE ` + table + ` (
+					id INTEGER PRIMARY KEY,
+					owner_id INTEGER,
+					CONSTRAINT fk_owner FOREIGN KEY (owner_id)
+						REFERENCES introspect_users (id)
+				)`)
+			}
+
+			// When two tables have a constraint with the same name, each table only
+			// gets its own
+			for _, table := range tables[:2] {
+				schema, err := Introspect(db, table)
+				require.NoError(t, err)
+				assert.Equal(t, []string{"id"}, schema.PrimaryKey)
+				require.Len(t, schema.ForeignKeys, 1)
+				assert.Equal(t, []string{"owner_id"}, schema.ForeignKeys[0].Columns)
+				assert.Equal(t, []string{"id"}, schema.ForeignKeys[0].RefColumns)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package azamat
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// NestColumns returns the table's columns, aliased with a prefix so that they are
+// scanned into a nested struct field, eg: users.id AS "author.id" is scanned into
+// the ID of the Author field of a Todo. Like with any other field, the nested field
+// can have a db tag instead, eg: `db:"author"`
+func NestColumns(table, prefix string, columns []string) (cols []string) {
+	for _, c := range columns {
+		cols = append(cols, fmt.Sprintf(`%s.%s AS "%s.%s"`, table, c, prefix, c))
+	}
+	return
+}
+
+// NestedColumns returns the table's Columns, aliased so they are scanned into a nested
+// struct field (see NestColumns)
+func (t Table[T]) NestedColumns(prefix string) []string {
+	return NestColumns(t.ref(), prefix, t.Columns)
+}
+
+// As returns a copy of the table that selects refer to by an alias, eg: to join a
+// table to itself. Inserts, updates, and deletes ignore the alias
+func (t Table[T]) As(alias string) Table[T] {
+	t.alias = alias
+	return t
+}
+
+// ref returns the name that columns are qualified with: the alias, if there is one
+func (t Table[T]) ref() string {
+	if t.alias != "" {
+		return t.alias
+	}
+	return t.Name
+}
+
+// from returns the table as it goes in a FROM or JOIN clause, eg: "users AS managers"
+func (t Table[T]) from() string {
+	if t.alias != "" {
+		return fmt.Sprintf("%s AS %s", t.Name, t.alias)
+	}
+	return t.Name
+}
+
+// JoinedTable is a Table of any row type, so it can be joined to a query of another
+// row type
+type JoinedTable interface {
+	String() string
+
+	// from returns the table as it goes in a JOIN clause
+	from() string
+
+	// nestedColumns returns the table's columns, aliased with a prefix
+	nestedColumns(prefix string) []string
+
+	// joinScopes are the predicates that limit which of the table's rows are joined
+	joinScopes() []sq.Sqlizer
+
+	selectTenant(ctx context.Context, b sq.SelectBuilder) (sq.SelectBuilder, error)
+}
+
+func (t Table[T]) nestedColumns(prefix string) []string {
+	return t.NestedColumns(prefix)
+}
+
+func (t Table[T]) joinScopes() []sq.Sqlizer {
+	scopes := t.scopes()
+	if scope := t.softDeleteScope(); scope != nil {
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// JoinNested joins another table, and selects its Columns into the struct field of T
+// that is named by the prefix (see NestColumns). The joined table's DefaultScopes,
+// soft deletes, and tenant isolation apply to the rows that are joined:
+//
+//	type Todo struct {
+//		ID     int
+//		Title  string
+//		Author User
+//	}
+//
+//	TodoTable.Select().JoinNested(UserTable, "author", "users.id = todos.author_id")
+func (b SelectBuilder[T]) JoinNested(
+	table JoinedTable, prefix, on string, args ...any,
+) SelectBuilder[T] {
+	b.SelectBuilder = b.SelectBuilder.
+		Columns(table.nestedColumns(prefix)...).
+		Join(fmt.Sprintf("%s ON %s", table.from(), on), args...)
+
+	for _, scope := range table.joinScopes() {
+		b.SelectBuilder = b.SelectBuilder.Where(scope)
+	}
+
+	b.hooks = append(append([]hook[sq.SelectBuilder]{}, b.hooks...), table.selectTenant)
+	return b
+}
+
+// Pair is a row of a Join: the row of the left table and the row of the right table
+type Pair[L, R any] struct {
+	Left  L `db:"left"`
+	Right R `db:"right"`
+}
+
+// JoinOn is the condition that a Join matches rows of L and R with
+type JoinOn[L, R any] struct {
+	left, right string
+}
+
+// On matches rows whose columns are equal. The columns have to hold the same Go type,
+// so eg: a todo's author_id can't be matched to a user's name
+func On[L, R, V any](left Column[L, V], right Column[R, V]) JoinOn[L, R] {
+	return JoinOn[L, R]{left: left.Name(), right: right.Name()}
+}
+
+// OnNullable is like On, but for a nullable left column (eg: a *int foreign key). Rows
+// where it is NULL don't match
+func OnNullable[L, R, V any](left Column[L, *V], right Column[R, V]) JoinOn[L, R] {
+	return JoinOn[L, R]{left: left.Name(), right: right.Name()}
+}
+
+// Join returns a Select query of the rows of two tables that match, as Pairs. The
+// scopes, soft deletes, and tenant isolation of both tables apply:
+//
+//	on := azamat.On(TodoCols.AuthorID, UserCols.ID)
+//	pairs, err := azamat.Join(TodoTable, UserTable, on).
+//		Where(UserCols.Name.Eq("Borat")).
+//		All(db)
+//
+// To join a table to itself, give at least one side an alias (see As). If either table
+// is Postgres, the query uses Postgres placeholders
+func Join[L, R any](
+	left Table[L], right Table[R], on JoinOn[L, R],
+) SelectBuilder[Pair[L, R]] {
+	query := sq.Select(left.NestedColumns("left")...).From(left.from())
+	if left.IsPostgres() || right.IsPostgres() {
+		query = psql.Select(left.NestedColumns("left")...).From(left.from())
+	}
+
+	b := SelectBuilder[Pair[L, R]]{
+		SelectBuilder: left.scoped(query),
+		hooks:         []hook[sq.SelectBuilder]{left.selectTenant},
+		dialect:       left.dialect(),
+	}
+	if right.IsPostgres() {
+		b.dialect = right.dialect()
+	}
+
+	if left.ref() == right.ref() {
+		err := fmt.Errorf("joining %s to itself needs an alias (see Table.As)", left.Name)
+		b.SelectBuilder = b.SelectBuilder.Where(errPredicate{err})
+	}
+
+	condition := fmt.Sprintf(
+		"%s.%s = %s.%s", right.ref(), on.right, left.ref(), on.left,
+	)
+	return b.JoinNested(right, "right", condition)
+}
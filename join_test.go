@@ -0,0 +1,258 @@
+package azamat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNestColumns(t *testing.T) {
+	require.Equal(
+		t,
+		[]string{`users.id AS "author.id"`, `users.name AS "author.name"`},
+		NestColumns("users", "author", []string{"id", "name"}),
+	)
+
+	UserTable := Table[struct{}]{Name: "users", Columns: []string{"id"}}
+	require.Equal(t, []string{`users.id AS "owner.id"`}, UserTable.NestedColumns("owner"))
+}
+
+func TestJoinNested(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID       int
+		Name     string
+		TenantID int `db:"tenant_id"`
+	}
+
+	type Todo struct {
+		ID       int
+		Title    string
+		AuthorID int `db:"author_id"`
+
+		Author User
+	}
+
+	UserTable := Table[User]{
+		Name:             "users",
+		Columns:          []string{"id", "name", "tenant_id"},
+		SoftDeleteColumn: "deleted_at",
+		TenantColumn:     "tenant_id",
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tenant_id INTEGER NOT NULL,
+			deleted_at DATETIME
+		`,
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "author_id"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			author_id INTEGER NOT NULL
+		`,
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO users (name, tenant_id)
+		VALUES ('Borat', 1), ('Azamat', 1), ('Pamela', 2)`)
+	db.MustExec(`INSERT INTO todos (title, author_id)
+		VALUES ('wrestle', 2), ('find Pamela', 1), ('wed', 3)`)
+
+	kazakhstan := WithTenant(context.Background(), 1)
+	query := TodoTable.Select().
+		JoinNested(UserTable, "author", "users.id = todos.author_id").
+		OrderBy("todos.id")
+
+	// When building the query...
+	built, err := query.build(kazakhstan)
+	require.NoError(t, err)
+
+	sql, _, err := built.ToSql()
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`SELECT todos.id, todos.title, todos.author_id, users.id AS "author.id", `+
+			`users.name AS "author.name", users.tenant_id AS "author.tenant_id" `+
+			`FROM todos JOIN users ON users.id = todos.author_id `+
+			`WHERE users.deleted_at IS NULL AND users.tenant_id = ? ORDER BY todos.id`,
+		sql,
+	)
+
+	// When scanning the joined rows...
+	todos, err := query.AllContext(kazakhstan, db)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{
+		{1, "wrestle", 2, User{2, "Azamat", 1}},
+		{2, "find Pamela", 1, User{1, "Borat", 1}},
+	}, todos)
+
+	// When the joined row is soft deleted...
+	_, err = UserTable.Delete().Where("id = ?", 2).Run(db)
+	require.Error(t, err)
+
+	_, err = UserTable.Delete().Where("id = ?", 2).RunContext(kazakhstan, db)
+	require.NoError(t, err)
+
+	todos, err = query.AllContext(kazakhstan, db)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{{2, "find Pamela", 1, User{1, "Borat", 1}}}, todos)
+
+	// When the joined table is tenant-scoped and there is no tenant...
+	_, err = query.All(db)
+	require.Equal(t, ErrNoTenant, err)
+}
+
+func TestJoin(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID        int
+		Name      string
+		ManagerID *int `db:"manager_id"`
+	}
+
+	type Todo struct {
+		ID       int
+		Title    string
+		AuthorID int `db:"author_id"`
+	}
+
+	UserTable := Table[User]{
+		Name:             "users",
+		Columns:          []string{"id", "name", "manager_id"},
+		SoftDeleteColumn: "deleted_at",
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			manager_id INTEGER,
+			deleted_at DATETIME
+		`,
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "author_id"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			author_id INTEGER NOT NULL
+		`,
+	}
+
+	UserCols := struct {
+		ID        Column[User, int]
+		Name      Column[User, string]
+		ManagerID Column[User, *int]
+	}{"users.id", "users.name", "users.manager_id"}
+
+	TodoCols := struct {
+		ID       Column[Todo, int]
+		AuthorID Column[Todo, int]
+	}{"todos.id", "todos.author_id"}
+
+	require.NoError(t, UserTable.Create(db))
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO users (name, manager_id)
+		VALUES ('Borat', NULL), ('Azamat', 1), ('Pamela', 1)`)
+	db.MustExec(`INSERT INTO todos (title, author_id)
+		VALUES ('wrestle', 2), ('find Pamela', 1), ('wed', 3)`)
+
+	borat := User{1, "Borat", nil}
+	azamat := User{2, "Azamat", &borat.ID}
+
+	// When joining two tables...
+	query := Join(TodoTable, UserTable, On(TodoCols.AuthorID, UserCols.ID)).
+		Where(UserCols.Name.In("Borat", "Azamat")).
+		OrderByClause(TodoCols.ID.Asc())
+
+	sql, args, err := query.ToSql()
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`SELECT todos.id AS "left.id", todos.title AS "left.title", `+
+			`todos.author_id AS "left.author_id", users.id AS "right.id", `+
+			`users.name AS "right.name", users.manager_id AS "right.manager_id" `+
+			`FROM todos JOIN users ON users.id = todos.author_id `+
+			`WHERE users.deleted_at IS NULL AND users.name IN (?,?) ORDER BY todos.id ASC`,
+		sql,
+	)
+	require.Equal(t, []any{"Borat", "Azamat"}, args)
+
+	pairs, err := query.All(db)
+	require.NoError(t, err)
+	require.Equal(t, []Pair[Todo, User]{
+		{Todo{1, "wrestle", 2}, azamat},
+		{Todo{2, "find Pamela", 1}, borat},
+	}, pairs)
+
+	// When either table is Postgres...
+	PostgresUsers := UserTable
+	PostgresUsers.Postgres = true
+
+	sql, _, err = Join(TodoTable, PostgresUsers, On(TodoCols.AuthorID, UserCols.ID)).
+		Where(UserCols.Name.Eq("Borat")).
+		Where(TodoCols.ID.Gt(1)).
+		ToSql()
+	require.NoError(t, err)
+	require.Contains(
+		t, sql, "WHERE users.deleted_at IS NULL AND users.name = $1 AND todos.id > $2",
+	)
+
+	// When joining a table to itself with an alias...
+	managers := UserTable.As("managers")
+	query2 := Join(UserTable, managers, OnNullable(UserCols.ManagerID, UserCols.ID)).
+		Where(UserCols.Name.Of("managers").Eq("Borat")).
+		OrderBy("users.id")
+
+	sql, _, err = query2.ToSql()
+	require.NoError(t, err)
+	require.Contains(
+		t, sql, "FROM users JOIN users AS managers ON managers.id = users.manager_id",
+	)
+	require.Contains(t, sql, "managers.deleted_at IS NULL")
+
+	users, err := query2.All(db)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, "Azamat", users[0].Left.Name)
+	require.Equal(t, "Pamela", users[1].Left.Name)
+	require.Equal(t, borat, users[1].Right)
+
+	// When joining a table to itself without an alias...
+	_, err = Join(UserTable, UserTable, OnNullable(UserCols.ManagerID, UserCols.ID)).All(db)
+	require.EqualError(t, err, "joining users to itself needs an alias (see Table.As)")
+}
+
+func TestTableAs(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	UserTable := Table[User]{
+		Name:             "users",
+		Columns:          []string{"id", "name"},
+		SoftDeleteColumn: "deleted_at",
+	}.As("u")
+
+	// When selecting, the alias is used
+	sql, _, err := UserTable.Select().ToSql()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT u.id, u.name FROM users AS u WHERE u.deleted_at IS NULL", sql)
+
+	// When deleting, it isn't
+	sql, _, err = UserTable.Delete().Where("id = ?", 1).ToSql()
+	require.NoError(t, err)
+	require.Contains(t, sql, "UPDATE users SET deleted_at = ?")
+	require.Contains(t, sql, "users.deleted_at IS NULL")
+}
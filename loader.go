@@ -0,0 +1,291 @@
+package azamat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrNotFound is returned by a Loader for keys that don't have a row
+var ErrNotFound = errors.New("not found")
+
+// Loader batches and caches lookups of a table's rows by ID, so that code that gets
+// rows one at a time (eg: GraphQL resolvers) doesn't run a query for each one. Keys
+// that are loaded within Wait of each other are fetched with a single query, and
+// every row is cached, so a Loader should only live as long as a request. Keys are
+// batched and cached separately for each tenant (see WithTenant), so one tenant never
+// gets the rows that were loaded for another:
+//
+//	loader := &azamat.Loader[int, User]{Table: UserTable, Runner: db}
+//	author, err := loader.Load(todo.AuthorID)
+//
+// A Loader must not be copied after it is first used
+type Loader[K comparable, T any] struct {
+	Table  Table[T]
+	Runner Runner
+
+	// Wait is how long a batch collects keys before it is fetched. Defaults to 1ms
+	Wait time.Duration
+
+	// MaxBatch is the most keys that are fetched at once. A batch is fetched as soon
+	// as it is full. If it is 0, there is no max
+	MaxBatch int
+
+	mu      sync.Mutex
+	cache   map[loaderKey[K]]*loaderResult[T]
+	batches map[string]*loaderBatch[K, T]
+}
+
+// loaderKey is a key as it is cached: along with the tenant it was loaded for
+type loaderKey[K comparable] struct {
+	tenant string
+	key    K
+}
+
+// loaderTenant identifies the tenant of a context, for splitting batches and the
+// cache. It is empty when there is no tenant
+func loaderTenant(ctx context.Context) string {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%T %#v", tenantID, tenantID)
+}
+
+type loaderResult[T any] struct {
+	done chan struct{}
+	row  T
+	err  error
+}
+
+type loaderBatch[K comparable, T any] struct {
+	ctx     context.Context
+	tenant  string
+	keys    []K
+	results []*loaderResult[T]
+	once    sync.Once
+}
+
+// Load gets the row with the given ID. It waits for the batch that the key is in to be
+// fetched. If there is no such row, the error is ErrNotFound
+func (l *Loader[K, T]) Load(key K) (T, error) {
+	return l.LoadContext(context.Background(), key)
+}
+
+// LoadMany gets the rows with the given IDs, in the same order. Each key has its own
+// error, so the rows that were found can be used even if others weren't
+func (l *Loader[K, T]) LoadMany(keys ...K) ([]T, []error) {
+	return l.LoadManyContext(context.Background(), keys...)
+}
+
+// LoadContext is like Load, but it stops waiting when the context is done, and the
+// key is loaded for the context's tenant. The query for a batch isn't cancelled along
+// with the context of one of its callers. Its other values come from the caller that
+// started the batch
+func (l *Loader[K, T]) LoadContext(ctx context.Context, key K) (T, error) {
+	result := l.request(ctx, key)
+
+	select {
+	case <-result.done:
+		return result.row, result.err
+	case <-ctx.Done():
+		var row T
+		return row, ctx.Err()
+	}
+}
+
+func (l *Loader[K, T]) LoadManyContext(
+	ctx context.Context, keys ...K,
+) ([]T, []error) {
+	// Every key is requested before waiting, so that they end up in the same batch
+	results := make([]*loaderResult[T], len(keys))
+	for i, key := range keys {
+		results[i] = l.request(ctx, key)
+	}
+
+	rows := make([]T, len(keys))
+	errs := make([]error, len(keys))
+	for i, result := range results {
+		select {
+		case <-result.done:
+			rows[i], errs[i] = result.row, result.err
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+	return rows, errs
+}
+
+// Prime adds a row to the cache, so loading its key doesn't have to query for it. It
+// doesn't replace a key that is already cached
+func (l *Loader[K, T]) Prime(key K, row T) {
+	l.PrimeContext(context.Background(), key, row)
+}
+
+// PrimeContext is like Prime, but the row is only cached for the context's tenant
+func (l *Loader[K, T]) PrimeContext(ctx context.Context, key K, row T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cacheKey := loaderKey[K]{loaderTenant(ctx), key}
+	if _, ok := l.cache[cacheKey]; ok {
+		return
+	}
+
+	result := &loaderResult[T]{done: make(chan struct{}), row: row}
+	close(result.done)
+
+	if l.cache == nil {
+		l.cache = map[loaderKey[K]]*loaderResult[T]{}
+	}
+	l.cache[cacheKey] = result
+}
+
+// Clear removes a key from the cache of every tenant, so that it is fetched again the
+// next time it is loaded (eg: after its row is updated)
+func (l *Loader[K, T]) Clear(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for cacheKey := range l.cache {
+		if cacheKey.key == key {
+			delete(l.cache, cacheKey)
+		}
+	}
+}
+
+// request returns the result for a key, adding the key to the current batch of the
+// context's tenant if it isn't cached
+func (l *Loader[K, T]) request(ctx context.Context, key K) *loaderResult[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tenant := loaderTenant(ctx)
+	cacheKey := loaderKey[K]{tenant, key}
+	if result, ok := l.cache[cacheKey]; ok {
+		return result
+	}
+
+	result := &loaderResult[T]{done: make(chan struct{})}
+	if l.cache == nil {
+		l.cache = map[loaderKey[K]]*loaderResult[T]{}
+	}
+	l.cache[cacheKey] = result
+
+	if l.batches == nil {
+		l.batches = map[string]*loaderBatch[K, T]{}
+	}
+
+	batch, ok := l.batches[tenant]
+	if !ok {
+		batch = &loaderBatch[K, T]{ctx: detachedContext{ctx}, tenant: tenant}
+		l.batches[tenant] = batch
+
+		wait := l.Wait
+		if wait == 0 {
+			wait = time.Millisecond
+		}
+		time.AfterFunc(wait, func() { l.dispatch(batch) })
+	}
+
+	batch.keys = append(batch.keys, key)
+	batch.results = append(batch.results, result)
+
+	if l.MaxBatch > 0 && len(batch.keys) >= l.MaxBatch {
+		delete(l.batches, tenant)
+		go l.dispatch(batch)
+	}
+
+	return result
+}
+
+// dispatch fetches a batch, unless it has already been fetched. A batch is
+// dispatched when it is full and when its Wait is over, whichever happens first
+func (l *Loader[K, T]) dispatch(batch *loaderBatch[K, T]) {
+	l.mu.Lock()
+	if l.batches[batch.tenant] == batch {
+		delete(l.batches, batch.tenant)
+	}
+	l.mu.Unlock()
+
+	batch.once.Do(func() { l.fetch(batch) })
+}
+
+func (l *Loader[K, T]) fetch(batch *loaderBatch[K, T]) {
+	idColumn := l.Table.idColumn()
+
+	rows, err := l.Table.Select().
+		Where(sq.Eq{idColumn: batch.keys}).
+		AllContext(batch.ctx, l.Runner)
+
+	if err != nil {
+		// Failures aren't cached, so the keys can be retried
+		l.mu.Lock()
+		for i, key := range batch.keys {
+			cacheKey := loaderKey[K]{batch.tenant, key}
+			if l.cache[cacheKey] == batch.results[i] {
+				delete(l.cache, cacheKey)
+			}
+		}
+		l.mu.Unlock()
+
+		for _, result := range batch.results {
+			result.err = err
+			close(result.done)
+		}
+		return
+	}
+
+	byID := map[string]T{}
+	for _, row := range rows {
+		id, _, keyErr := relationKey(row, idColumn)
+		if keyErr != nil {
+			err = keyErr
+			break
+		}
+		byID[id] = row
+	}
+
+	for i, key := range batch.keys {
+		result := batch.results[i]
+
+		id, _ := normalizeKey(key)
+		row, ok := byID[id]
+		switch {
+		case err != nil:
+			result.err = err
+		case !ok:
+			result.err = ErrNotFound
+		default:
+			result.row = row
+		}
+
+		close(result.done)
+	}
+}
+
+// detachedContext has the values of its parent, but is never cancelled. A batch is
+// shared by many callers, so it shouldn't fail when one of them gives up
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,252 @@
+package azamat
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	UserTable := Table[User]{
+		Name:      "users",
+		Columns:   []string{"id", "name"},
+		RawSchema: "id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL",
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	db.MustExec(`INSERT INTO users (name) VALUES ('Borat'), ('Azamat'), ('Pamela')`)
+
+	// Count the queries, by scoping the table with a predicate that counts its calls
+	var mu sync.Mutex
+	queries := 0
+	UserTable.DefaultScopes = append(UserTable.DefaultScopes, countingPredicate{func() {
+		mu.Lock()
+		queries++
+		mu.Unlock()
+	}})
+	queryCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return queries
+	}
+
+	loader := &Loader[int, User]{Table: UserTable, Runner: db, Wait: 10 * time.Millisecond}
+
+	// When keys are loaded at the same time, they are fetched in one query
+	var wg sync.WaitGroup
+	users := make([]User, 3)
+	errs := make([]error, 3)
+	for i, id := range []int{3, 1, 4} {
+		wg.Add(1)
+		go func(i, id int) {
+			defer wg.Done()
+			users[i], errs[i] = loader.Load(id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, queryCount())
+	require.Equal(t, User{3, "Pamela"}, users[0])
+	require.NoError(t, errs[0])
+	require.Equal(t, User{1, "Borat"}, users[1])
+	require.NoError(t, errs[1])
+	require.Equal(t, ErrNotFound, errs[2])
+
+	// When keys are cached, they aren't fetched again
+	user, err := loader.Load(1)
+	require.NoError(t, err)
+	require.Equal(t, User{1, "Borat"}, user)
+	require.Equal(t, 1, queryCount())
+
+	// When loading many keys, they are returned in order
+	users, errs = loader.LoadMany(2, 1, 5, 2)
+	require.Equal(t, []User{{2, "Azamat"}, {1, "Borat"}, {}, {2, "Azamat"}}, users)
+	require.Equal(t, []error{nil, nil, ErrNotFound, nil}, errs)
+	require.Equal(t, 2, queryCount())
+
+	// When a key is primed or cleared
+	loader.Prime(6, User{6, "Luenell"})
+	user, err = loader.LoadContext(context.Background(), 6)
+	require.NoError(t, err)
+	require.Equal(t, User{6, "Luenell"}, user)
+	require.Equal(t, 2, queryCount())
+
+	db.MustExec(`UPDATE users SET name = 'Borat Sagdiyev' WHERE id = 1`)
+	loader.Clear(1)
+	user, err = loader.Load(1)
+	require.NoError(t, err)
+	require.Equal(t, "Borat Sagdiyev", user.Name)
+	require.Equal(t, 3, queryCount())
+
+	// When batches are full, they are fetched right away
+	loader = &Loader[int, User]{Table: UserTable, Runner: db, Wait: time.Hour, MaxBatch: 2}
+	users, errs = loader.LoadMany(1, 2)
+	require.Equal(t, []User{{1, "Borat Sagdiyev"}, {2, "Azamat"}}, users)
+	require.Equal(t, []error{nil, nil}, errs)
+
+	// When the query fails, the error isn't cached
+	loader = &Loader[int, User]{Table: UserTable, Runner: db}
+	db.MustExec("ALTER TABLE users RENAME TO people")
+
+	_, err = loader.Load(1)
+	require.Error(t, err)
+	require.NotEqual(t, ErrNotFound, err)
+
+	db.MustExec("ALTER TABLE people RENAME TO users")
+	user, err = loader.Load(1)
+	require.NoError(t, err)
+	require.Equal(t, "Borat Sagdiyev", user.Name)
+}
+
+func TestLoaderCancel(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	UserTable := Table[User]{
+		Name:      "users",
+		Columns:   []string{"id", "name"},
+		RawSchema: "id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL",
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	db.MustExec(`INSERT INTO users (name) VALUES ('Borat'), ('Azamat')`)
+
+	loader := &Loader[int, User]{Table: UserTable, Runner: db, Wait: 50 * time.Millisecond}
+
+	// The batch is started by a caller that gives up before it is fetched
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := loader.LoadContext(ctx, 1)
+		cancelled <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	others := make(chan []error)
+	go func() {
+		_, errs := loader.LoadManyContext(context.Background(), 1, 2)
+		others <- errs
+	}()
+
+	// When a caller's context is cancelled, it stops waiting right away...
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-cancelled:
+		require.Equal(t, context.Canceled, err)
+	case <-time.After(25 * time.Millisecond):
+		t.Fatal("LoadContext didn't return when its context was cancelled")
+	}
+
+	// ...but the other callers in the batch still get their rows
+	require.Equal(t, []error{nil, nil}, <-others)
+
+	user, err := loader.Load(1)
+	require.NoError(t, err)
+	require.Equal(t, User{1, "Borat"}, user)
+
+	// When the context is already cancelled, and the key isn't cached
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, errs := loader.LoadManyContext(ctx, 3)
+	require.Equal(t, []error{context.Canceled}, errs)
+}
+
+func TestLoaderTenants(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type User struct {
+		ID       int
+		Name     string
+		TenantID int `db:"tenant_id"`
+	}
+
+	UserTable := Table[User]{
+		Name:         "users",
+		Columns:      []string{"id", "name", "tenant_id"},
+		TenantColumn: "tenant_id",
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tenant_id INTEGER NOT NULL
+		`,
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	db.MustExec(`INSERT INTO users (name, tenant_id)
+		VALUES ('Borat', 1), ('Pamela', 2)`)
+
+	kazakhstan := WithTenant(context.Background(), 1)
+	america := WithTenant(context.Background(), 2)
+
+	loader := &Loader[int, User]{Table: UserTable, Runner: db}
+
+	// When another tenant's row was loaded (and cached) first, it isn't returned
+	user, err := loader.LoadContext(america, 2)
+	require.NoError(t, err)
+	require.Equal(t, User{2, "Pamela", 2}, user)
+
+	_, err = loader.LoadContext(kazakhstan, 2)
+	require.Equal(t, ErrNotFound, err)
+
+	// When tenants load keys at the same time, they are batched separately
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, ctx := range []context.Context{kazakhstan, america} {
+		wg.Add(1)
+		go func(i int, ctx context.Context) {
+			defer wg.Done()
+			_, errs[i] = loader.LoadContext(ctx, 1)
+		}(i, ctx)
+	}
+	wg.Wait()
+
+	require.NoError(t, errs[0])
+	require.Equal(t, ErrNotFound, errs[1])
+
+	// When a row is primed for a tenant, other tenants don't get it
+	loader.PrimeContext(kazakhstan, 3, User{3, "Azamat", 1})
+	user, err = loader.LoadContext(kazakhstan, 3)
+	require.NoError(t, err)
+	require.Equal(t, "Azamat", user.Name)
+
+	_, err = loader.LoadContext(america, 3)
+	require.Equal(t, ErrNotFound, err)
+
+	// When a key is cleared, it is cleared for every tenant
+	loader.Clear(1)
+	db.MustExec(`UPDATE users SET name = 'Borat Sagdiyev' WHERE id = 1`)
+	user, err = loader.LoadContext(kazakhstan, 1)
+	require.NoError(t, err)
+	require.Equal(t, "Borat Sagdiyev", user.Name)
+}
+
+// countingPredicate is a predicate that is always true, and calls count when it is
+// built
+type countingPredicate struct {
+	count func()
+}
+
+func (p countingPredicate) ToSql() (string, []interface{}, error) {
+	p.count()
+	return "1=1", nil, nil
+}
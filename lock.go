@@ -0,0 +1,84 @@
+package azamat
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// lockClause is a row locking clause like FOR UPDATE SKIP LOCKED
+type lockClause struct {
+	strength string // UPDATE or SHARE
+	wait     string // NOWAIT or SKIP LOCKED
+}
+
+// ForUpdate locks the selected rows so other transactions can't update or lock them
+// until the current transaction ends. It has no effect on SQLite, which doesn't
+// support row locks (but only allows one transaction to write at a time), or when
+// the dialect isn't known (eg: ToSql on a Table that isn't Postgres)
+func (b SelectBuilder[T]) ForUpdate() SelectBuilder[T] {
+	b.lock.strength = "UPDATE"
+	return b
+}
+
+// ForShare locks the selected rows so other transactions can't update them until the
+// current transaction ends. It has no effect on SQLite
+func (b SelectBuilder[T]) ForShare() SelectBuilder[T] {
+	b.lock.strength = "SHARE"
+	return b
+}
+
+// NoWait makes the query fail instead of waiting when a row is already locked. It
+// implies ForUpdate, unless ForShare is used
+func (b SelectBuilder[T]) NoWait() SelectBuilder[T] {
+	b.lock.wait = "NOWAIT"
+	return b
+}
+
+// SkipLocked makes the query skip rows that are already locked instead of waiting
+// for them. This is useful for claiming work from a queue. It implies ForUpdate,
+// unless ForShare is used
+func (b SelectBuilder[T]) SkipLocked() SelectBuilder[T] {
+	b.lock.wait = "SKIP LOCKED"
+	return b
+}
+
+// applyLock adds the builder's locking clause to the query, as supported by its
+// dialect. SQLite doesn't support it, and neither might an unknown dialect, so the
+// clause is only added for Postgres and MySQL
+func (b SelectBuilder[T]) applyLock(query sq.SelectBuilder) sq.SelectBuilder {
+	if b.lock == (lockClause{}) {
+		return query
+	}
+	if b.dialect != DialectPostgres && b.dialect != DialectMySQL {
+		return query
+	}
+
+	strength := b.lock.strength
+	if strength == "" {
+		strength = "UPDATE"
+	}
+
+	clause := "FOR " + strength
+	if b.lock.wait != "" {
+		clause += " " + b.lock.wait
+	}
+
+	return query.Suffix(clause)
+}
+
+// LockByID gets the row with the given ID and locks it FOR UPDATE. Since a lock only
+// lasts until the end of the transaction, it has to be run in one
+func (t Table[T]) LockByID(tx *sqlx.Tx, id int) (T, error) {
+	return t.LockByIDContext(context.Background(), tx, id)
+}
+
+func (t Table[T]) LockByIDContext(
+	ctx context.Context, tx *sqlx.Tx, id int,
+) (T, error) {
+	return t.Select().
+		Where(sq.Eq{t.idColumn(): id}).
+		ForUpdate().
+		OnlyContext(ctx, tx)
+}
@@ -0,0 +1,101 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectLocking(t *testing.T) {
+	type Job struct {
+		ID   int
+		Name string
+	}
+
+	JobTable := Table[Job]{
+		Name:     "jobs",
+		Columns:  []string{"id", "name"},
+		Postgres: true,
+	}
+
+	type testCase struct {
+		query    SelectBuilder[Job]
+		expected string
+	}
+
+	base := "SELECT jobs.id, jobs.name FROM jobs LIMIT 1"
+
+	cases := []testCase{
+		{query: JobTable.Select(), expected: base},
+		{query: JobTable.Select().ForUpdate(), expected: base + " FOR UPDATE"},
+		{query: JobTable.Select().ForShare(), expected: base + " FOR SHARE"},
+		{
+			query:    JobTable.Select().ForUpdate().NoWait(),
+			expected: base + " FOR UPDATE NOWAIT",
+		},
+		{
+			query:    JobTable.Select().SkipLocked(),
+			expected: base + " FOR UPDATE SKIP LOCKED",
+		},
+		{
+			query:    JobTable.Select().ForShare().SkipLocked(),
+			expected: base + " FOR SHARE SKIP LOCKED",
+		},
+	}
+
+	for _, c := range cases {
+		sql, _, err := c.query.Limit(1).ToSql()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, sql)
+	}
+
+	// When the dialect is SQLite, or isn't known, there is no locking clause
+	JobTable.Postgres = false
+
+	sql, _, err := JobTable.Select().ForUpdate().Limit(1).ToSql()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT jobs.id, jobs.name FROM jobs LIMIT 1", sql)
+
+	query := JobTable.Select().SkipLocked().Limit(1)
+	query.dialect = DialectSQLite
+
+	sql, _, err = query.ToSql()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT jobs.id, jobs.name FROM jobs LIMIT 1", sql)
+}
+
+func TestTableLockByID(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Job struct {
+		ID   int
+		Name string
+	}
+
+	JobTable := Table[Job]{
+		Name:    "jobs",
+		Columns: []string{"id", "name"},
+	}
+
+	db.MustExec(`CREATE TABLE jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	)`)
+
+	db.MustExec(`INSERT INTO jobs (name) VALUES (?), (?)`, "assist Borat", "find Pamela")
+
+	// On SQLite, the locking clause is left out so the query still works
+	err := CommitTransaction(db, func(tx *sqlx.Tx) error {
+		job, err := JobTable.LockByID(tx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "find Pamela", job.Name)
+
+		jobs, err := JobTable.Select().ForUpdate().SkipLocked().All(tx)
+		require.NoError(t, err)
+		assert.Len(t, jobs, 2)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,375 @@
+package azamat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration is a versioned change to the db schema. A migration either runs Go
+// functions (Up and Down) or SQL (UpSQL and DownSQL). Down/DownSQL are optional, but
+// a migration without them can't be rolled back
+type Migration struct {
+	Version int64
+	Name    string
+
+	Up   func(runner Runner) error
+	Down func(runner Runner) error
+
+	UpSQL   string
+	DownSQL string
+}
+
+// Checksum identifies the contents of a SQL migration, so we can tell if it was
+// edited after it was applied. Go migrations don't have a checksum
+func (m Migration) Checksum() string {
+	if m.UpSQL == "" && m.DownSQL == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(m.UpSQL + "\x00" + m.DownSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+func (m Migration) run(runner Runner, fn func(Runner) error, sql string) error {
+	if fn != nil {
+		return fn(runner)
+	}
+
+	if strings.TrimSpace(sql) == "" {
+		return nil
+	}
+
+	_, err := runner.Exec(sql)
+	return err
+}
+
+// MigrationsFromFS loads SQL migrations from a directory of a file system, such as an
+// embed.FS. Files are named like 0001_create_todos.up.sql and
+// 0001_create_todos.down.sql, where the leading number is the version
+func MigrationsFromFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(filename, ".sql")
+		direction := path.Ext(base)
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("%s should end in .up.sql or .down.sql", filename)
+		}
+		base = strings.TrimSuffix(base, direction)
+
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s should start with a version number", filename)
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != name {
+			return nil, fmt.Errorf(
+				"version %d is used by %s and %s", version, migration.Name, name,
+			)
+		}
+
+		if direction == ".up" {
+			migration.UpSQL = string(contents)
+		} else {
+			migration.DownSQL = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// ErrChecksumMismatch is returned when a migration was edited after it was applied
+type ErrChecksumMismatch struct {
+	Version int64
+	Name    string
+}
+
+func (e ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf(
+		"migration %d_%s was changed after it was applied", e.Version, e.Name,
+	)
+}
+
+// Migrator applies and rolls back migrations. Applied migrations are tracked in a
+// table. Each migration is run in a transaction, except on MySQL where DDL statements
+// can't be rolled back anyway
+type Migrator struct {
+	Migrations []Migration
+
+	// TableName is the name of the table that tracks applied migrations. Defaults
+	// to "schema_migrations"
+	TableName string
+
+	// Clock works like it does for Table
+	Clock func() time.Time
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Migration Migration
+	Applied   bool
+	AppliedAt time.Time
+
+	// Changed is set if the migration was edited after it was applied
+	Changed bool
+}
+
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+func (m Migrator) table(db *sqlx.DB) Table[appliedMigration] {
+	name := m.TableName
+	if name == "" {
+		name = "schema_migrations"
+	}
+
+	return Table[appliedMigration]{
+		Name:    name,
+		Columns: []string{"version", "name", "checksum", "applied_at"},
+		RawSchema: `
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		`,
+		IDColumn:        "version",
+		Postgres:        DialectOf(db) == DialectPostgres,
+		CreatedAtColumn: "applied_at",
+		Clock:           m.Clock,
+	}
+}
+
+// applied creates the tracking table if needed and returns the applied migrations,
+// keyed by version. It also makes sure no two migrations have the same version
+func (m Migrator) applied(db *sqlx.DB) (map[int64]appliedMigration, error) {
+	seen := map[int64]bool{}
+	for _, migration := range m.Migrations {
+		if seen[migration.Version] {
+			return nil, fmt.Errorf(
+				"version %d is used more than once", migration.Version,
+			)
+		}
+		seen[migration.Version] = true
+	}
+
+	t := m.table(db)
+	if err := t.CreateIfNotExists(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := t.GetAll(db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int64]appliedMigration{}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+func (m Migrator) sorted() []Migration {
+	migrations := append([]Migration(nil), m.Migrations...)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+// Status returns the status of every migration, in order
+func (m Migrator) Status(db *sqlx.DB) ([]MigrationStatus, error) {
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.sorted() {
+		row, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: row.AppliedAt,
+			Changed:   ok && row.Checksum != migration.Checksum(),
+		})
+	}
+	return statuses, nil
+}
+
+// Up applies all of the migrations that haven't been applied yet
+func (m Migrator) Up(db *sqlx.DB) error {
+	migrations := m.sorted()
+	if len(migrations) == 0 {
+		return nil
+	}
+	return m.To(db, migrations[len(migrations)-1].Version)
+}
+
+// Down rolls back the most recently applied migration
+func (m Migrator) Down(db *sqlx.DB) error {
+	applied, err := m.applied(db)
+	if err != nil {
+		return err
+	}
+
+	var target int64
+	var found bool
+	migrations := m.sorted()
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].Version]; !ok {
+			continue
+		}
+
+		if found {
+			target = migrations[i].Version
+			break
+		}
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+	return m.To(db, target)
+}
+
+// To migrates up or down so that every migration up to and including the given
+// version is applied, and every migration after it is not. Use version 0 to roll back
+// every migration
+func (m Migrator) To(db *sqlx.DB, version int64) error {
+	applied, err := m.applied(db)
+	if err != nil {
+		return err
+	}
+
+	migrations := m.sorted()
+	for _, migration := range migrations {
+		row, ok := applied[migration.Version]
+		if ok && row.Checksum != migration.Checksum() {
+			return ErrChecksumMismatch{
+				Version: migration.Version,
+				Name:    migration.Name,
+			}
+		}
+	}
+
+	// Roll back, newest first
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+
+		if err := m.down(db, migration); err != nil {
+			return err
+		}
+	}
+
+	// Apply, oldest first
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+
+		if err := m.up(db, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m Migrator) up(db *sqlx.DB, migration Migration) error {
+	return m.transaction(db, func(runner Runner) error {
+		err := migration.run(runner, migration.Up, migration.UpSQL)
+		if err != nil {
+			return fmt.Errorf("migrating up %s: %w", migration, err)
+		}
+
+		_, err = m.table(db).
+			Insert().
+			Columns("version", "name", "checksum").
+			Values(migration.Version, migration.Name, migration.Checksum()).
+			Run(runner)
+		return err
+	})
+}
+
+func (m Migrator) down(db *sqlx.DB, migration Migration) error {
+	if migration.Down == nil && migration.DownSQL == "" {
+		return fmt.Errorf("%s can't be rolled back", migration)
+	}
+
+	return m.transaction(db, func(runner Runner) error {
+		err := migration.run(runner, migration.Down, migration.DownSQL)
+		if err != nil {
+			return fmt.Errorf("migrating down %s: %w", migration, err)
+		}
+
+		_, err = m.table(db).
+			HardDelete().
+			Where(sq.Eq{"version": migration.Version}).
+			Run(runner)
+		return err
+	})
+}
+
+// transaction runs fn in a transaction, if the dialect can roll back schema changes
+func (m Migrator) transaction(db *sqlx.DB, fn func(Runner) error) error {
+	if DialectOf(db) == DialectMySQL {
+		return fn(db)
+	}
+
+	return CommitTransaction(db, func(tx *sqlx.Tx) error {
+		return fn(tx)
+	})
+}
@@ -0,0 +1,145 @@
+package azamat
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_completed.up.sql": {
+			Data: []byte("ALTER TABLE todos ADD COLUMN completed BOOLEAN"),
+		},
+		"migrations/0001_create_todos.up.sql": {
+			Data: []byte("CREATE TABLE todos (id INTEGER PRIMARY KEY)"),
+		},
+		"migrations/0001_create_todos.down.sql": {
+			Data: []byte("DROP TABLE todos"),
+		},
+		"migrations/README.md": {Data: []byte("very nice")},
+	}
+
+	migrations, err := MigrationsFromFS(fsys, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_todos", migrations[0].Name)
+	assert.Equal(t, "CREATE TABLE todos (id INTEGER PRIMARY KEY)", migrations[0].UpSQL)
+	assert.Equal(t, "DROP TABLE todos", migrations[0].DownSQL)
+
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "add_completed", migrations[1].Name)
+	assert.Empty(t, migrations[1].DownSQL)
+
+	// When a file isn't named correctly...
+	fsys["migrations/create_users.up.sql"] = &fstest.MapFile{}
+	_, err = MigrationsFromFS(fsys, "migrations")
+	require.Error(t, err)
+}
+
+func TestMigrator(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	tableExists := func(name string) bool {
+		var count int
+		db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE name = ?", name)
+		return count == 1
+	}
+
+	migrator := Migrator{
+		Migrations: []Migration{
+			{
+				Version: 2,
+				Name:    "create_users",
+				Up: func(runner Runner) error {
+					_, err := runner.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY)")
+					return err
+				},
+				Down: func(runner Runner) error {
+					_, err := runner.Exec("DROP TABLE users")
+					return err
+				},
+			},
+			{
+				Version: 1,
+				Name:    "create_todos",
+				UpSQL:   "CREATE TABLE todos (id INTEGER PRIMARY KEY)",
+				DownSQL: "DROP TABLE todos",
+			},
+		},
+	}
+
+	// Before anything is applied...
+	statuses, err := migrator.Status(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	// When migrating to a specific version...
+	err = migrator.To(db, 1)
+	require.NoError(t, err)
+	assert.True(t, tableExists("todos"))
+	assert.False(t, tableExists("users"))
+
+	// When migrating all the way up...
+	err = migrator.Up(db)
+	require.NoError(t, err)
+	assert.True(t, tableExists("users"))
+
+	statuses, err = migrator.Status(db)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.True(t, statuses[1].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+
+	// When there is nothing left to apply...
+	err = migrator.Up(db)
+	require.NoError(t, err)
+
+	// When rolling back...
+	err = migrator.Down(db)
+	require.NoError(t, err)
+	assert.True(t, tableExists("todos"))
+	assert.False(t, tableExists("users"))
+
+	// When a migration fails, it is rolled back
+	migrator.Migrations = append(migrator.Migrations, Migration{
+		Version: 3,
+		Name:    "broken",
+		UpSQL:   "CREATE TABLE tags (id INTEGER); SELECT * FROM nope",
+	})
+
+	err = migrator.Up(db)
+	require.Error(t, err)
+	assert.True(t, tableExists("users"))
+	assert.False(t, tableExists("tags"))
+
+	statuses, err = migrator.Status(db)
+	require.NoError(t, err)
+	assert.True(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+
+	// When an applied migration was edited...
+	migrator.Migrations[1].UpSQL = "CREATE TABLE todos (id INTEGER)"
+
+	statuses, err = migrator.Status(db)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Changed)
+
+	err = migrator.To(db, 0)
+	require.Equal(t, ErrChecksumMismatch{Version: 1, Name: "create_todos"}, err)
+
+	// When rolling back everything...
+	migrator.Migrations[1].UpSQL = "CREATE TABLE todos (id INTEGER PRIMARY KEY)"
+	err = migrator.To(db, 0)
+	require.NoError(t, err)
+	assert.False(t, tableExists("todos"))
+	assert.False(t, tableExists("users"))
+}
@@ -0,0 +1,199 @@
+package azamat
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// Outbox implements the transactional outbox pattern. Events are written to an outbox
+// table in the same transaction as the domain rows they describe, so either both are
+// written or neither is. A Relay later reads the events and publishes them.
+type Outbox struct {
+	// TableName is the name of the table that holds the events. Defaults to "outbox"
+	TableName string
+
+	// Postgres works like it does for Table
+	Postgres bool
+
+	// Clock works like it does for Table
+	Clock func() time.Time
+}
+
+// Event is an event in an Outbox
+type Event struct {
+	ID          int64
+	Topic       string
+	Payload     []byte       // JSON encoded
+	CreatedAt   time.Time    `db:"created_at"`
+	DeliveredAt sql.NullTime `db:"delivered_at"`
+}
+
+// Table returns the definition of the outbox table
+func (o Outbox) Table() Table[Event] {
+	name := o.TableName
+	if name == "" {
+		name = "outbox"
+	}
+
+	schema := managedSchema(
+		ColumnDef{Name: "topic", Type: TypeText},
+		ColumnDef{Name: "payload", Type: TypeText},
+		ColumnDef{Name: "created_at", Type: TypeTimestamp},
+		ColumnDef{Name: "delivered_at", Type: TypeTimestamp, Nullable: true},
+	)
+
+	return Table[Event]{
+		Name:            name,
+		Columns:         schema.columnNames(),
+		Schema:          schema,
+		Postgres:        o.Postgres,
+		CreatedAtColumn: "created_at",
+		Clock:           o.Clock,
+	}
+}
+
+// CreateTable creates the outbox table, if it doesn't already exist
+func (o Outbox) CreateTable(db *sqlx.DB) error {
+	return o.Table().CreateIfNotExists(db)
+}
+
+// Emit writes an event to the outbox. It takes a transaction because the point of the
+// outbox is to write events atomically with the changes they describe, so it should
+// be called inside of CommitTransaction. The payload is stored as JSON
+func (o Outbox) Emit(tx *sqlx.Tx, topic string, payload any) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.Table().
+		Insert().
+		Columns("topic", "payload").
+		Values(topic, string(encoded)).
+		Run(tx)
+	return err
+}
+
+// Undelivered returns the events that haven't been delivered yet, oldest first
+func (o Outbox) Undelivered(runner Runner) ([]Event, error) {
+	return o.undelivered().All(runner)
+}
+
+func (o Outbox) undelivered() SelectBuilder[Event] {
+	return o.Table().
+		Select().
+		Where(sq.Eq{"delivered_at": nil}).
+		OrderBy("id")
+}
+
+// Publisher delivers events to wherever they need to go (eg: a message broker)
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// PublisherFunc lets an ordinary function be used as a Publisher
+type PublisherFunc func(ctx context.Context, event Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Relay polls an Outbox for undelivered events and hands them to a Publisher, in the
+// order of their ids. Delivery is at-least-once: if the relay crashes after
+// publishing an event but before marking it as delivered, the event is published
+// again, so consumers should be idempotent.
+//
+// Ids are handed out when events are emitted, not when their transactions commit, so
+// on Postgres and MySQL an event can become visible after one with a higher id that
+// was already relayed. It is still relayed on a later poll, just out of order: if
+// consumers need a strict order, they should order by something in the payload
+type Relay struct {
+	Outbox    Outbox
+	Publisher Publisher
+
+	// BatchSize is the most events that are relayed per poll. Defaults to 100
+	BatchSize uint64
+
+	// Interval is how long Run waits between polls. Defaults to 1 second
+	Interval time.Duration
+
+	// OnError is called when a poll fails in Run. Run keeps going either way
+	OnError func(error)
+}
+
+// RelayOnce relays a single batch of events and returns how many were delivered. If
+// publishing an event fails, the events before it are still marked as delivered,
+// but the rest of the batch is left for the next poll so that order is preserved
+func (r Relay) RelayOnce(ctx context.Context, db *sqlx.DB) (int, error) {
+	batchSize := r.BatchSize
+	if batchSize == 0 {
+		batchSize = 100
+	}
+
+	var delivered []int64
+	var publishErr error
+	t := r.Outbox.Table()
+
+	err := CommitTransaction(db, func(tx *sqlx.Tx) error {
+		// Lock the batch so that relays running at the same time wait for each
+		// other instead of publishing the same events
+		events, err := r.Outbox.
+			undelivered().
+			Limit(batchSize).
+			ForUpdate().
+			AllContext(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if publishErr = r.Publisher.Publish(ctx, event); publishErr != nil {
+				break
+			}
+			delivered = append(delivered, event.ID)
+		}
+
+		if len(delivered) == 0 {
+			return nil
+		}
+
+		_, err = t.Update().
+			Set("delivered_at", t.now()).
+			Where(sq.Eq{"id": delivered}).
+			RunContext(ctx, tx)
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return len(delivered), publishErr
+}
+
+// Run relays events until the context is done
+func (r Relay) Run(ctx context.Context, db *sqlx.DB) error {
+	interval := r.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayOnce(ctx, db); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
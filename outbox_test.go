@@ -0,0 +1,130 @@
+package azamat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL
+		`,
+	}
+
+	var outbox Outbox
+	require.NoError(t, TodoTable.Create(db))
+	require.NoError(t, outbox.CreateTable(db))
+
+	createTodo := func(title string) error {
+		return CommitTransaction(db, func(tx *sqlx.Tx) error {
+			insert := TodoTable.Insert().Columns("title").Values(title)
+			if _, err := insert.Run(tx); err != nil {
+				return err
+			}
+			return outbox.Emit(tx, "todo.created", map[string]string{"title": title})
+		})
+	}
+
+	// When the transaction commits, the event is written with the row
+	require.NoError(t, createTodo("assist Borat"))
+	require.NoError(t, createTodo("find Pamela"))
+
+	// When the transaction fails, neither is written
+	err := CommitTransaction(db, func(tx *sqlx.Tx) error {
+		err := outbox.Emit(tx, "todo.created", map[string]string{"title": "oops"})
+		require.NoError(t, err)
+		return fmt.Errorf("999 syntax error")
+	})
+	require.Error(t, err)
+
+	events, err := outbox.Undelivered(db)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "todo.created", events[0].Topic)
+	assert.JSONEq(t, `{"title": "assist Borat"}`, string(events[0].Payload))
+
+	// When the publisher fails partway through a batch...
+	var published []string
+	failOn := "find Pamela"
+	publisher := PublisherFunc(func(ctx context.Context, event Event) error {
+		var payload map[string]string
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return err
+		}
+
+		if payload["title"] == failOn {
+			return fmt.Errorf("broker is down")
+		}
+
+		published = append(published, payload["title"])
+		return nil
+	})
+
+	relay := Relay{Outbox: outbox, Publisher: publisher}
+	delivered, err := relay.RelayOnce(context.Background(), db)
+	require.Error(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{"assist Borat"}, published)
+
+	// Make sure the events before the failure were marked as delivered
+	events, err = outbox.Undelivered(db)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	// When the publisher recovers...
+	failOn = ""
+	delivered, err = relay.RelayOnce(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Equal(t, []string{"assist Borat", "find Pamela"}, published)
+
+	events, err = outbox.Undelivered(db)
+	require.NoError(t, err)
+	assert.Len(t, events, 0)
+
+	// When there is nothing to relay...
+	delivered, err = relay.RelayOnce(context.Background(), db)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+}
+
+func TestOutboxSchema(t *testing.T) {
+	schema := Outbox{}.Table().Schema
+
+	// When the outbox is created on MySQL, the id is an AUTO_INCREMENT column
+	require.Equal(
+		t,
+		"CREATE TABLE outbox ("+
+			"id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, "+
+			"topic TEXT NOT NULL, "+
+			"payload TEXT NOT NULL, "+
+			"created_at DATETIME NOT NULL, "+
+			"delivered_at DATETIME)",
+		schema.CreateTableSQL("outbox", DialectMySQL),
+	)
+
+	// When it is created on Postgres, the id is a BIGSERIAL
+	require.Contains(
+		t,
+		schema.CreateTableSQL("outbox", DialectPostgres),
+		"id BIGSERIAL NOT NULL PRIMARY KEY",
+	)
+}
@@ -0,0 +1,326 @@
+package azamat
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Filter operators for QueryParams. In a query string, an operator other than OpEq
+// goes in brackets after the column, eg: ?priority[gt]=2
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+	OpLike = "like"
+
+	// OpIn takes comma separated values, eg: ?status[in]=open,blocked
+	OpIn = "in"
+
+	// OpNull takes true (IS NULL) or false (IS NOT NULL), eg: ?assignee[null]=true
+	OpNull = "null"
+)
+
+// Reserved query parameters
+const (
+	// ParamSort is a comma separated list of columns to sort by. A column that starts
+	// with "-" is sorted in descending order, eg: ?sort=-created_at,title
+	ParamSort = "sort"
+
+	ParamLimit  = "limit"
+	ParamOffset = "offset"
+)
+
+// QueryParams is the allowlist for filtering, sorting, and paginating a query with
+// query parameters (see SelectBuilder.ApplyParams). Columns that aren't in it can't
+// be used, so the parameters can come straight from users
+type QueryParams struct {
+	// Filters maps the columns that can be filtered on to their allowed operators. A
+	// column without operators only allows OpEq
+	Filters map[string][]string
+
+	// Sorts are the columns that can be sorted by
+	Sorts []string
+
+	// DefaultSort is used when there is no sort parameter, eg: "-created_at"
+	DefaultSort string
+
+	// DefaultLimit is used when there is no limit parameter. If it is 0, there is no
+	// limit
+	DefaultLimit uint64
+
+	// MaxLimit is the highest limit that can be asked for. If it is 0, there is no max
+	MaxLimit uint64
+}
+
+// ParamError is a problem with a query parameter
+type ParamError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+func (e ParamError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+// ParamErrors are all of the problems with a set of query parameters, so they can
+// be reported at once (eg: in a 400 response)
+type ParamErrors []ParamError
+
+func (e ParamErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ApplyParams validates query parameters against the allowlist and adds their
+// filters, sorting, and pagination to the query. Filters are ANDed together, and
+// their values are converted to the Go type of the column's field in T. If any
+// parameter is invalid, the error is ParamErrors and the query is returned as is.
+// Columns are qualified with the name (or alias) of the query's Table, so they aren't
+// ambiguous in a join
+func (b SelectBuilder[T]) ApplyParams(
+	values url.Values, params QueryParams,
+) (SelectBuilder[T], error) {
+	var errs ParamErrors
+	invalid := func(param, format string, args ...any) {
+		errs = append(errs, ParamError{param, fmt.Sprintf(format, args...)})
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var row T
+	rowType := reflect.TypeOf(row)
+
+	query := b
+	for _, key := range keys {
+		if len(values[key]) != 1 {
+			invalid(key, "can only be given once")
+			continue
+		}
+		if key == ParamSort || key == ParamLimit || key == ParamOffset {
+			continue
+		}
+		value := values[key][0]
+
+		column, op := key, OpEq
+		if i := strings.Index(key, "["); i >= 0 && strings.HasSuffix(key, "]") {
+			column, op = key[:i], key[i+1:len(key)-1]
+		}
+
+		ops, ok := params.Filters[column]
+		if !ok {
+			invalid(key, "can't filter on %s", column)
+			continue
+		}
+		if len(ops) == 0 {
+			ops = []string{OpEq}
+		}
+		if !contains(ops, op) {
+			invalid(key, "can't filter on %s with %s", column, op)
+			continue
+		}
+
+		predicate, err := paramPredicate(
+			b.qualify(column), op, value, columnType(rowType, column),
+		)
+		if err != nil {
+			invalid(key, "%s", err)
+			continue
+		}
+		query = query.Where(predicate)
+	}
+
+	sorts := params.DefaultSort
+	if len(values[ParamSort]) == 1 {
+		sorts = values.Get(ParamSort)
+	}
+	for _, column := range strings.Split(sorts, ",") {
+		if column == "" {
+			continue
+		}
+
+		direction := "ASC"
+		if strings.HasPrefix(column, "-") {
+			column, direction = column[1:], "DESC"
+		}
+
+		if !contains(params.Sorts, column) {
+			invalid(ParamSort, "can't sort by %s", column)
+			continue
+		}
+		query = query.OrderBy(b.qualify(column) + " " + direction)
+	}
+
+	limit := params.DefaultLimit
+	if len(values[ParamLimit]) == 1 {
+		n, err := strconv.ParseUint(values.Get(ParamLimit), 10, 64)
+		switch {
+		case err != nil || n == 0:
+			invalid(ParamLimit, "has to be a positive integer")
+		case params.MaxLimit > 0 && n > params.MaxLimit:
+			invalid(ParamLimit, "can't be more than %d", params.MaxLimit)
+		default:
+			limit = n
+		}
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if len(values[ParamOffset]) == 1 {
+		n, err := strconv.ParseUint(values.Get(ParamOffset), 10, 64)
+		if err != nil {
+			invalid(ParamOffset, "has to be a non-negative integer")
+		} else {
+			query = query.Offset(n)
+		}
+	}
+
+	if len(errs) > 0 {
+		return b, errs
+	}
+	return query, nil
+}
+
+// qualify prefixes a column with the name (or alias) of the query's Table, if it has
+// one and the column isn't already qualified
+func (b SelectBuilder[T]) qualify(column string) string {
+	if b.ref == "" || strings.Contains(column, ".") {
+		return column
+	}
+	return b.ref + "." + column
+}
+
+func paramPredicate(column, op, value string, typ reflect.Type) (sq.Sqlizer, error) {
+	switch op {
+	case OpNull:
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("has to be true or false")
+		}
+		if isNull {
+			return sq.Eq{column: nil}, nil
+		}
+		return sq.NotEq{column: nil}, nil
+
+	case OpLike:
+		return sq.Like{column: value}, nil
+
+	case OpIn:
+		var in []any
+		for _, s := range strings.Split(value, ",") {
+			v, err := parseValue(s, typ)
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, v)
+		}
+		return sq.Eq{column: in}, nil
+	}
+
+	v, err := parseValue(value, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case OpEq:
+		return sq.Eq{column: v}, nil
+	case OpNe:
+		return sq.NotEq{column: v}, nil
+	case OpGt:
+		return sq.Gt{column: v}, nil
+	case OpGte:
+		return sq.GtOrEq{column: v}, nil
+	case OpLt:
+		return sq.Lt{column: v}, nil
+	case OpLte:
+		return sq.LtOrEq{column: v}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+// columnType returns the Go type of the field that holds a column, or nil if the row
+// isn't a struct or has no such field
+func columnType(row reflect.Type, column string) reflect.Type {
+	if row == nil || row.Kind() != reflect.Struct {
+		return nil
+	}
+
+	index, err := fieldIndex(row, column)
+	if err != nil {
+		return nil
+	}
+	return row.FieldByIndex(index).Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parseValue converts a string from a user into a value of the given Go type. Types
+// that it doesn't know about (and a nil type) leave the string as it is
+func parseValue(value string, typ reflect.Type) (any, error) {
+	if typ == nil {
+		return value, nil
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ == timeType {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a time", value)
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return n, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a non-negative integer", value)
+		}
+		return n, nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return f, nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,182 @@
+package azamat
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyParams(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+		Priority  *int
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "completed", "priority"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			completed BOOLEAN NOT NULL,
+			priority INTEGER
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO todos (title, completed, priority) VALUES
+		('buy milk', true, 1),
+		('buy bread', false, 3),
+		('sell milk', false, NULL),
+		('sell bread', true, 2)
+	`)
+
+	params := QueryParams{
+		Filters: map[string][]string{
+			"completed": nil,
+			"title":     {OpEq, OpLike},
+			"priority":  {OpGt, OpLte, OpIn, OpNull},
+		},
+		Sorts:        []string{"id", "priority"},
+		DefaultSort:  "-id",
+		DefaultLimit: 3,
+		MaxLimit:     10,
+	}
+
+	titles := func(query string) []string {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+
+		q, err := TodoTable.Select().ApplyParams(values, params)
+		require.NoError(t, err)
+
+		todos, err := q.All(db)
+		require.NoError(t, err)
+
+		titles := []string{}
+		for _, todo := range todos {
+			titles = append(titles, todo.Title)
+		}
+		return titles
+	}
+
+	// When there are no params, the defaults are used
+	require.Equal(t, []string{"sell bread", "sell milk", "buy bread"}, titles(""))
+
+	// When filtering
+	require.Equal(t, []string{"sell bread", "buy milk"}, titles("completed=true"))
+	require.Equal(t, []string{"buy milk"}, titles("completed=true&title[like]=buy%25"))
+	require.Equal(t, []string{"sell bread", "buy bread"}, titles("priority[gt]=1"))
+	require.Equal(t, []string{"sell bread", "buy milk"}, titles("priority[in]=1,2"))
+	require.Equal(t, []string{"sell milk"}, titles("priority[null]=true"))
+
+	// When sorting and paginating
+	require.Equal(
+		t,
+		[]string{"buy milk", "sell bread", "buy bread"},
+		titles("sort=priority,id&priority[lte]=3&limit=10"),
+	)
+	require.Equal(t, []string{"buy bread", "sell milk"}, titles("sort=id&limit=2&offset=1"))
+
+	// When params are invalid, every problem is reported
+	values, _ := url.ParseQuery(
+		"secret=1&title[gt]=a&completed=maybe&completed[eq]=true&completed[eq]=false" +
+			"&sort=-password&limit=100&offset=-1&offset=2",
+	)
+	_, err := TodoTable.Select().ApplyParams(values, params)
+	require.Equal(t, ParamErrors{
+		{"completed", `"maybe" is not a boolean`},
+		{"completed[eq]", "can only be given once"},
+		{"offset", "can only be given once"},
+		{"secret", "can't filter on secret"},
+		{"title[gt]", "can't filter on title with gt"},
+		{"sort", "can't sort by password"},
+		{"limit", "can't be more than 10"},
+	}, err)
+	require.Equal(t, "secret: can't filter on secret", err.(ParamErrors)[3].Error())
+
+	// When sort, limit, or offset are given more than once
+	values, _ = url.ParseQuery("sort=id&sort=-id&limit=1&limit=2")
+	_, err = TodoTable.Select().ApplyParams(values, params)
+	require.Equal(t, ParamErrors{
+		{"limit", "can only be given once"},
+		{"sort", "can only be given once"},
+	}, err)
+}
+
+func TestApplyParamsJoined(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	type Todo struct {
+		ID       int
+		Title    string
+		AuthorID int `db:"author_id"`
+	}
+
+	UserTable := Table[User]{
+		Name:    "users",
+		Columns: []string{"id", "name"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL
+		`,
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "author_id"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			author_id INTEGER NOT NULL
+		`,
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO users (name) VALUES ('borat'), ('azamat')`)
+	db.MustExec(`INSERT INTO todos (title, author_id) VALUES
+		('wrestle', 2),
+		('film', 1),
+		('marry pamela', 1)
+	`)
+
+	params := QueryParams{
+		Filters: map[string][]string{"id": {OpGt}},
+		Sorts:   []string{"id"},
+	}
+
+	values, _ := url.ParseQuery("id[gt]=1&sort=-id")
+
+	// When the query joins a table with the same columns, they aren't ambiguous
+	q, err := TodoTable.Select().
+		Join("users ON users.id = todos.author_id").
+		Where("users.name = ?", "borat").
+		ApplyParams(values, params)
+	require.NoError(t, err)
+
+	todos, err := q.All(db)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{{3, "marry pamela", 1}, {2, "film", 1}}, todos)
+
+	// When the table is aliased, the columns use the alias
+	q, err = TodoTable.As("t").Select().ApplyParams(values, params)
+	require.NoError(t, err)
+
+	sql, _, err := q.ToSql()
+	require.NoError(t, err)
+	require.Contains(t, sql, "WHERE t.id > ? ORDER BY t.id DESC")
+}
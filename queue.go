@@ -0,0 +1,393 @@
+package azamat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNoJobs is returned by Claim when there are no jobs that are ready to run
+var ErrNoJobs = errors.New("no jobs ready to run")
+
+// ErrLeaseLost is returned when a worker tries to do something with a job it no
+// longer holds, ie: the lease expired and the job was claimed by another worker
+var ErrLeaseLost = errors.New("job lease was lost")
+
+// Queue is a job queue that is stored in a db table. Jobs carry a payload of type P,
+// which is stored as JSON. Multiple queues can share the same table, as long as they
+// have different names.
+//
+// A worker Claims a job, which leases it for some amount of time. While the worker is
+// processing the job, nobody else can claim it. The worker can extend its lease with
+// Heartbeat. When it's done, it either Acks the job (which removes it from the queue)
+// or Fails it (which schedules a retry, or dead-letters the job once it runs out of
+// attempts). If the worker dies, the lease expires and the job can be claimed again.
+type Queue[P any] struct {
+	// Name of the queue
+	Name string
+
+	// TableName is the name of the table that holds the jobs. Defaults to "jobs"
+	TableName string
+
+	// Postgres works like it does for Table
+	Postgres bool
+
+	// MaxAttempts is how many times a job can be claimed before it is dead-lettered.
+	// Defaults to 5
+	MaxAttempts int
+
+	// Lease is how long a claimed job is held before other workers can claim it.
+	// Defaults to 5 minutes
+	Lease time.Duration
+
+	// Backoff returns how long to wait before retrying a job that has failed the given
+	// number of attempts. Defaults to exponential backoff, starting at 1 second and
+	// capped at 1 hour
+	Backoff func(attempts int) time.Duration
+
+	// Clock works like it does for Table
+	Clock func() time.Time
+}
+
+// Job is a job in a Queue
+type Job[P any] struct {
+	ID          int64
+	Payload     P
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LockedUntil time.Time
+	LastError   string
+	FailedAt    time.Time
+	CreatedAt   time.Time
+}
+
+// jobRow is how a job is stored in the db
+type jobRow struct {
+	ID          int64
+	Queue       string
+	Payload     string
+	Attempts    int
+	MaxAttempts int            `db:"max_attempts"`
+	RunAt       time.Time      `db:"run_at"`
+	LockedUntil sql.NullTime   `db:"locked_until"`
+	LastError   sql.NullString `db:"last_error"`
+	FailedAt    sql.NullTime   `db:"failed_at"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+func (q Queue[P]) table() Table[jobRow] {
+	name := q.TableName
+	if name == "" {
+		name = "jobs"
+	}
+
+	schema := managedSchema(
+		ColumnDef{Name: "queue", Type: TypeText},
+		ColumnDef{Name: "payload", Type: TypeText},
+		ColumnDef{Name: "attempts", Type: TypeInteger, Default: "0"},
+		ColumnDef{Name: "max_attempts", Type: TypeInteger},
+		ColumnDef{Name: "run_at", Type: TypeTimestamp},
+		ColumnDef{Name: "locked_until", Type: TypeTimestamp, Nullable: true},
+		ColumnDef{Name: "last_error", Type: TypeText, Nullable: true},
+		ColumnDef{Name: "failed_at", Type: TypeTimestamp, Nullable: true},
+		ColumnDef{Name: "created_at", Type: TypeTimestamp},
+	)
+
+	return Table[jobRow]{
+		Name:            name,
+		Columns:         schema.columnNames(),
+		Schema:          schema,
+		Postgres:        q.Postgres,
+		CreatedAtColumn: "created_at",
+		Clock:           q.now,
+	}
+}
+
+func (q Queue[P]) now() time.Time {
+	if q.Clock != nil {
+		return q.Clock().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (q Queue[P]) maxAttempts() int {
+	if q.MaxAttempts > 0 {
+		return q.MaxAttempts
+	}
+	return 5
+}
+
+func (q Queue[P]) lease() time.Duration {
+	if q.Lease > 0 {
+		return q.Lease
+	}
+	return 5 * time.Minute
+}
+
+func (q Queue[P]) backoff(attempts int) time.Duration {
+	if q.Backoff != nil {
+		return q.Backoff(attempts)
+	}
+	seconds := math.Pow(2, float64(attempts-1))
+	if seconds >= maxBackoff.Seconds() {
+		return maxBackoff
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// maxBackoff caps the default backoff, which would otherwise overflow a Duration
+// after enough attempts
+const maxBackoff = time.Hour
+
+// CreateTable creates the queue's table, if it doesn't already exist
+func (q Queue[P]) CreateTable(db *sqlx.DB) error {
+	return q.table().CreateIfNotExists(db)
+}
+
+// Enqueue adds a job to the queue that is ready to run right away
+func (q Queue[P]) Enqueue(runner Runner, payload P) (int64, error) {
+	return q.EnqueueAt(runner, payload, q.now())
+}
+
+// EnqueueAt adds a job to the queue that won't run until the given time
+func (q Queue[P]) EnqueueAt(
+	runner Runner, payload P, runAt time.Time,
+) (int64, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	t := q.table()
+	insert := t.Insert().
+		Columns("queue", "payload", "max_attempts", "run_at").
+		Values(q.Name, string(encoded), q.maxAttempts(), runAt.UTC())
+
+	// lib/pq doesn't support LastInsertId
+	if t.IsPostgres() {
+		var id int64
+		err := insert.Suffix("RETURNING id").RunWith(runner).QueryRow().Scan(&id)
+		return id, err
+	}
+
+	result, err := insert.Run(runner)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Claim leases the next job that is ready to run. If there isn't one, it returns
+// ErrNoJobs. Jobs are claimed in the order they are scheduled to run
+func (q Queue[P]) Claim(db *sqlx.DB) (Job[P], error) {
+	var job Job[P]
+	t := q.table()
+	now := q.now()
+
+	// A job whose worker died on its last attempt can't be claimed again, so it is
+	// dead-lettered once its lease expires
+	_, err := t.Update().
+		Set("locked_until", nil).
+		Set("last_error", "lease expired on the last attempt").
+		Set("failed_at", now).
+		Where(sq.Eq{"queue": q.Name, "failed_at": nil}).
+		Where(sq.Lt{"locked_until": now}).
+		Where("attempts >= max_attempts").
+		Run(db)
+	if err != nil {
+		return job, err
+	}
+
+	err = CommitTransaction(db, func(tx *sqlx.Tx) error {
+		rows, err := t.Select().
+			Where(sq.Eq{"queue": q.Name, "failed_at": nil}).
+			Where(sq.LtOrEq{"run_at": now}).
+			Where("attempts < max_attempts").
+			Where(sq.Or{
+				sq.Eq{"locked_until": nil},
+				sq.Lt{"locked_until": now},
+			}).
+			OrderBy("run_at", "id").
+			Limit(1).
+			ForUpdate().
+			SkipLocked().
+			All(tx)
+		if err != nil {
+			return err
+		}
+
+		if len(rows) == 0 {
+			return ErrNoJobs
+		}
+
+		row := rows[0]
+		row.Attempts++
+		row.LockedUntil = sql.NullTime{Time: now.Add(q.lease()), Valid: true}
+
+		update := t.Update().
+			Set("attempts", row.Attempts).
+			Set("locked_until", row.LockedUntil).
+			Where(sq.Eq{"id": row.ID})
+
+		if _, err := update.Run(tx); err != nil {
+			return err
+		}
+
+		job, err = q.decode(row)
+		return err
+	})
+
+	return job, err
+}
+
+// Heartbeat extends the lease on a job that is still being worked on
+func (q Queue[P]) Heartbeat(runner Runner, job *Job[P]) error {
+	lockedUntil := q.now().Add(q.lease())
+
+	update := q.table().Update().Set("locked_until", lockedUntil)
+	if err := q.runLeased(runner, job, update); err != nil {
+		return err
+	}
+
+	job.LockedUntil = lockedUntil
+	return nil
+}
+
+// Ack marks a job as done, which removes it from the queue
+func (q Queue[P]) Ack(runner Runner, job Job[P]) error {
+	result, err := q.table().
+		Delete().
+		Where(leased(job.ID, job.Attempts)).
+		Run(runner)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(result)
+}
+
+// Fail releases a job that couldn't be processed. If the job has attempts left, it
+// is retried after a backoff. Otherwise, it is dead-lettered. A nil jobErr is recorded
+// as an empty error message
+func (q Queue[P]) Fail(runner Runner, job *Job[P], jobErr error) error {
+	now := q.now()
+	runAt, failedAt := job.RunAt, time.Time{}
+
+	lastError := ""
+	if jobErr != nil {
+		lastError = jobErr.Error()
+	}
+
+	update := q.table().
+		Update().
+		Set("locked_until", nil).
+		Set("last_error", lastError)
+
+	if job.Attempts >= job.MaxAttempts {
+		failedAt = now
+		update = update.Set("failed_at", failedAt)
+	} else {
+		runAt = now.Add(q.backoff(job.Attempts))
+		update = update.Set("run_at", runAt)
+	}
+
+	if err := q.runLeased(runner, job, update); err != nil {
+		return err
+	}
+
+	job.RunAt = runAt
+	job.FailedAt = failedAt
+	job.LockedUntil = time.Time{}
+	job.LastError = lastError
+	return nil
+}
+
+// DeadLetters returns the jobs that ran out of attempts
+func (q Queue[P]) DeadLetters(runner Runner) ([]Job[P], error) {
+	rows, err := q.table().
+		Select().
+		Where(sq.Eq{"queue": q.Name}).
+		Where(sq.NotEq{"failed_at": nil}).
+		OrderBy("id").
+		All(runner)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job[P], 0, len(rows))
+	for _, row := range rows {
+		job, err := q.decode(row)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Requeue gives a dead-lettered job a fresh set of attempts and makes it ready to run
+func (q Queue[P]) Requeue(runner Runner, id int64) error {
+	_, err := q.table().
+		Update().
+		Set("attempts", 0).
+		Set("failed_at", nil).
+		Set("run_at", q.now()).
+		Where(sq.Eq{"id": id, "queue": q.Name}).
+		Where(sq.NotEq{"failed_at": nil}).
+		Run(runner)
+	return err
+}
+
+// runLeased runs an update on a job, as long as the worker still holds its lease
+func (q Queue[P]) runLeased(runner Runner, job *Job[P], update UpdateBuilder) error {
+	result, err := update.Where(leased(job.ID, job.Attempts)).Run(runner)
+	if err != nil {
+		return err
+	}
+
+	return checkLease(result)
+}
+
+// leased matches a job that is still leased by the worker that claimed it on the given
+// attempt. The number of attempts is used as a fencing token: if the job has been
+// claimed again since, or has been released (by Fail) or dead-lettered, it doesn't match
+func leased(id int64, attempts int) sq.And {
+	return sq.And{
+		sq.Eq{"id": id, "attempts": attempts, "failed_at": nil},
+		sq.NotEq{"locked_until": nil},
+	}
+}
+
+func checkLease(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (q Queue[P]) decode(row jobRow) (Job[P], error) {
+	job := Job[P]{
+		ID:          row.ID,
+		Attempts:    row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+		RunAt:       row.RunAt,
+		LockedUntil: row.LockedUntil.Time,
+		LastError:   row.LastError.String,
+		FailedAt:    row.FailedAt.Time,
+		CreatedAt:   row.CreatedAt,
+	}
+
+	err := json.Unmarshal([]byte(row.Payload), &job.Payload)
+	return job, err
+}
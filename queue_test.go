@@ -0,0 +1,207 @@
+package azamat
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1) // each connection would get its own in-memory db
+
+	type Email struct {
+		To      string
+		Subject string
+	}
+
+	now := time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	EmailQueue := Queue[Email]{
+		Name:        "emails",
+		MaxAttempts: 2,
+		Lease:       time.Minute,
+		Backoff:     func(attempts int) time.Duration { return time.Hour },
+		Clock:       func() time.Time { return now },
+	}
+
+	err := EmailQueue.CreateTable(db)
+	require.NoError(t, err)
+
+	// When the queue is empty...
+	_, err = EmailQueue.Claim(db)
+	require.Equal(t, ErrNoJobs, err)
+
+	// Enqueue some jobs
+	borat := Email{To: "borat@aol.com", Subject: "very nice"}
+	pamela := Email{To: "pamela@aol.com", Subject: "wawaweewa"}
+	bear := Email{To: "bear@aol.com", Subject: "food"}
+
+	boratID, err := EmailQueue.Enqueue(db, borat)
+	require.NoError(t, err)
+
+	_, err = EmailQueue.EnqueueAt(db, bear, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	pamelaID, err := EmailQueue.Enqueue(db, pamela)
+	require.NoError(t, err)
+
+	// When claiming jobs...
+	job1, err := EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, boratID, job1.ID)
+	assert.Equal(t, borat, job1.Payload)
+	assert.Equal(t, 1, job1.Attempts)
+	assert.True(t, now.Add(time.Minute).Equal(job1.LockedUntil))
+
+	job2, err := EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, pamelaID, job2.ID)
+
+	// Make sure the scheduled job isn't claimed before it's ready
+	_, err = EmailQueue.Claim(db)
+	require.Equal(t, ErrNoJobs, err)
+
+	// When a job is acked...
+	err = EmailQueue.Ack(db, job1)
+	require.NoError(t, err)
+
+	// When a job fails, it is retried after a backoff
+	err = EmailQueue.Fail(db, &job2, fmt.Errorf("mailbox full"))
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Hour).Equal(job2.RunAt))
+
+	_, err = EmailQueue.Claim(db)
+	require.Equal(t, ErrNoJobs, err)
+
+	// An hour later, both the scheduled job and the retry are ready
+	now = now.Add(time.Hour)
+
+	job3, err := EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, bear, job3.Payload)
+
+	job2, err = EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, pamelaID, job2.ID)
+	assert.Equal(t, 2, job2.Attempts)
+	assert.Equal(t, "mailbox full", job2.LastError)
+
+	// When a job runs out of attempts, it is dead-lettered
+	err = EmailQueue.Fail(db, &job2, fmt.Errorf("mailbox still full"))
+	require.NoError(t, err)
+
+	deadLetters, err := EmailQueue.DeadLetters(db)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, pamelaID, deadLetters[0].ID)
+	assert.Equal(t, "mailbox still full", deadLetters[0].LastError)
+
+	// When a worker heartbeats, its lease is extended
+	now = now.Add(30 * time.Second)
+	err = EmailQueue.Heartbeat(db, &job3)
+	require.NoError(t, err)
+
+	now = now.Add(45 * time.Second)
+	_, err = EmailQueue.Claim(db)
+	require.Equal(t, ErrNoJobs, err)
+
+	// When a lease expires, another worker can claim the job...
+	now = now.Add(time.Minute)
+	reclaimed, err := EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, job3.ID, reclaimed.ID)
+
+	// ...and the original worker can no longer ack it
+	err = EmailQueue.Ack(db, job3)
+	require.Equal(t, ErrLeaseLost, err)
+
+	err = EmailQueue.Ack(db, reclaimed)
+	require.NoError(t, err)
+
+	// When a dead-lettered job is requeued...
+	err = EmailQueue.Requeue(db, pamelaID)
+	require.NoError(t, err)
+
+	job2, err = EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, pamelaID, job2.ID)
+	assert.Equal(t, 1, job2.Attempts)
+
+	deadLetters, err = EmailQueue.DeadLetters(db)
+	require.NoError(t, err)
+	assert.Len(t, deadLetters, 0)
+
+	// When the lease expires on the last attempt, the job isn't claimed again...
+	now = now.Add(2 * time.Minute)
+	job2, err = EmailQueue.Claim(db)
+	require.NoError(t, err)
+	assert.Equal(t, 2, job2.Attempts)
+
+	now = now.Add(2 * time.Minute)
+	_, err = EmailQueue.Claim(db)
+	require.Equal(t, ErrNoJobs, err)
+
+	// ...it is dead-lettered instead
+	deadLetters, err = EmailQueue.DeadLetters(db)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, pamelaID, deadLetters[0].ID)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+	assert.Equal(t, "lease expired on the last attempt", deadLetters[0].LastError)
+}
+
+func TestQueueReleasedJobs(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	now := time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	TaskQueue := Queue[string]{
+		Name:        "tasks",
+		MaxAttempts: 1,
+		Clock:       func() time.Time { return now },
+	}
+
+	require.NoError(t, TaskQueue.CreateTable(db))
+
+	_, err := TaskQueue.Enqueue(db, "wrestle bear")
+	require.NoError(t, err)
+
+	job, err := TaskQueue.Claim(db)
+	require.NoError(t, err)
+
+	// When a job fails without an error, it is recorded with an empty message
+	err = TaskQueue.Fail(db, &job, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", job.LastError)
+	assert.False(t, job.FailedAt.IsZero())
+
+	// When the worker uses the job after failing it, its lease is gone
+	stale := job
+	stale.FailedAt = time.Time{}
+	require.Equal(t, ErrLeaseLost, TaskQueue.Heartbeat(db, &stale))
+	require.Equal(t, ErrLeaseLost, TaskQueue.Fail(db, &stale, fmt.Errorf("again")))
+	require.Equal(t, ErrLeaseLost, TaskQueue.Ack(db, stale))
+
+	deadLetters, err := TaskQueue.DeadLetters(db)
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "", deadLetters[0].LastError)
+}
+
+func TestQueueBackoff(t *testing.T) {
+	var q Queue[string]
+
+	assert.Equal(t, time.Second, q.backoff(1))
+	assert.Equal(t, 8*time.Second, q.backoff(4))
+
+	// When there have been enough attempts, the backoff is capped instead of overflowing
+	assert.Equal(t, time.Hour, q.backoff(13))
+	assert.Equal(t, time.Hour, q.backoff(100))
+	assert.Equal(t, time.Hour, q.backoff(1000))
+}
@@ -0,0 +1,385 @@
+package azamat
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Relation is a relationship from the rows of a Table[T] to the rows of another
+// table. Relations are declared in a Table's Relations, and loaded with Preload
+type Relation[T any] interface {
+	// preload loads the related rows of every row, and puts them in the field
+	preload(
+		ctx context.Context, runner Runner, parent Table[T], rows []T, field string,
+	) error
+}
+
+// BelongsTo is a relation where each row of T has a foreignKey column with the ID of
+// a row of the related table (eg: a todo's author_id). Its field is an R or a *R
+func BelongsTo[T, R any](related Table[R], foreignKey string) Relation[T] {
+	return belongsTo[T, R]{related: related, foreignKey: foreignKey}
+}
+
+// HasMany is a relation where the rows of the related table have a foreignKey column
+// with the ID of a row of T (eg: a user's todos have an author_id). Its field is a
+// []R or a []*R
+func HasMany[T, R any](related Table[R], foreignKey string) Relation[T] {
+	return hasMany[T, R]{related: related, foreignKey: foreignKey}
+}
+
+// ManyToMany is a relation through a join table, whose parentKey column has the ID of
+// a row of T and whose relatedKey column has the ID of a row of the related table
+// (eg: todo_tags has todo_id and tag_id). Its field is a []R or a []*R
+func ManyToMany[T, R any](
+	related Table[R], joinTable, parentKey, relatedKey string,
+) Relation[T] {
+	return manyToMany[T, R]{
+		related:    related,
+		joinTable:  joinTable,
+		parentKey:  parentKey,
+		relatedKey: relatedKey,
+	}
+}
+
+// Preload loads the given Relations of the rows, with one query per relation (and
+// two for ManyToMany), and puts them in the rows' fields of the same names
+func (t Table[T]) Preload(runner Runner, rows []T, relations ...string) error {
+	return t.PreloadContext(context.Background(), runner, rows, relations...)
+}
+
+func (t Table[T]) PreloadContext(
+	ctx context.Context, runner Runner, rows []T, relations ...string,
+) error {
+	for _, name := range relations {
+		relation, ok := t.Relations[name]
+		if !ok {
+			return fmt.Errorf("%s has no relation %s", t.Name, name)
+		}
+
+		if err := relation.preload(ctx, runner, t, rows, name); err != nil {
+			return fmt.Errorf("preload %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+type belongsTo[T, R any] struct {
+	related    Table[R]
+	foreignKey string
+}
+
+func (r belongsTo[T, R]) preload(
+	ctx context.Context, runner Runner, parent Table[T], rows []T, field string,
+) error {
+	if err := checkRelationField[T, R](field, false); err != nil {
+		return err
+	}
+
+	keys, err := relationKeys(rows, r.foreignKey)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	related, err := r.related.Select().
+		Where(sq.Eq{r.related.idColumn(): keys}).
+		AllContext(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	byID := map[string]*R{}
+	for i := range related {
+		id, _, err := relationKey(related[i], r.related.idColumn())
+		if err != nil {
+			return err
+		}
+		byID[id] = &related[i]
+	}
+
+	for i := range rows {
+		key, ok, err := relationKey(rows[i], r.foreignKey)
+		if err != nil {
+			return err
+		}
+
+		if match := byID[key]; ok && match != nil {
+			setOne(reflect.ValueOf(&rows[i]).Elem().FieldByName(field), match)
+		}
+	}
+	return nil
+}
+
+type hasMany[T, R any] struct {
+	related    Table[R]
+	foreignKey string
+}
+
+func (r hasMany[T, R]) preload(
+	ctx context.Context, runner Runner, parent Table[T], rows []T, field string,
+) error {
+	if err := checkRelationField[T, R](field, true); err != nil {
+		return err
+	}
+
+	keys, err := relationKeys(rows, parent.idColumn())
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	related, err := r.related.Select().
+		Where(sq.Eq{r.foreignKey: keys}).
+		OrderBy(r.related.idColumn()).
+		AllContext(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	byParent := map[string][]R{}
+	for _, row := range related {
+		key, _, err := relationKey(row, r.foreignKey)
+		if err != nil {
+			return err
+		}
+		byParent[key] = append(byParent[key], row)
+	}
+
+	for i := range rows {
+		key, _, err := relationKey(rows[i], parent.idColumn())
+		if err != nil {
+			return err
+		}
+		setMany(reflect.ValueOf(&rows[i]).Elem().FieldByName(field), byParent[key])
+	}
+	return nil
+}
+
+type manyToMany[T, R any] struct {
+	related    Table[R]
+	joinTable  string
+	parentKey  string
+	relatedKey string
+}
+
+func (r manyToMany[T, R]) preload(
+	ctx context.Context, runner Runner, parent Table[T], rows []T, field string,
+) error {
+	if err := checkRelationField[T, R](field, true); err != nil {
+		return err
+	}
+
+	keys, err := relationKeys(rows, parent.idColumn())
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	query := sq.Select()
+	if parent.IsPostgres() || r.related.IsPostgres() {
+		query = psql.Select()
+	}
+
+	sql, args, err := query.
+		Column(r.parentKey + " AS parent_key").
+		Column(r.relatedKey + " AS related_key").
+		From(r.joinTable).
+		Where(sq.Eq{r.parentKey: keys}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var links []struct {
+		ParentKey  any `db:"parent_key"`
+		RelatedKey any `db:"related_key"`
+	}
+	if err := withContext(runner).SelectContext(ctx, &links, sql, args...); err != nil {
+		return err
+	}
+
+	relatedKeys := []any{}
+	for _, link := range links {
+		relatedKeys = append(relatedKeys, link.RelatedKey)
+	}
+
+	related, err := r.related.Select().
+		Where(sq.Eq{r.related.idColumn(): relatedKeys}).
+		OrderBy(r.related.idColumn()).
+		AllContext(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	// Related rows are kept in the order of the query, so they are sorted by ID
+	order := map[string]int{}
+	for i, row := range related {
+		id, _, err := relationKey(row, r.related.idColumn())
+		if err != nil {
+			return err
+		}
+		order[id] = i
+	}
+
+	byParent := map[string][]int{}
+	for _, link := range links {
+		parentKey, _ := normalizeKey(link.ParentKey)
+		relatedKey, _ := normalizeKey(link.RelatedKey)
+
+		if i, ok := order[relatedKey]; ok {
+			byParent[parentKey] = append(byParent[parentKey], i)
+		}
+	}
+
+	for i := range rows {
+		key, _, err := relationKey(rows[i], parent.idColumn())
+		if err != nil {
+			return err
+		}
+
+		indexes := byParent[key]
+		sort.Ints(indexes)
+
+		matches := make([]R, len(indexes))
+		for j, index := range indexes {
+			matches[j] = related[index]
+		}
+		setMany(reflect.ValueOf(&rows[i]).Elem().FieldByName(field), matches)
+	}
+	return nil
+}
+
+// checkRelationField checks that T has a field that can hold the related rows: an R
+// or *R, or if many, a []R or []*R
+func checkRelationField[T, R any](name string, many bool) error {
+	var row T
+	var related R
+	rowType, relatedType := reflect.TypeOf(row), reflect.TypeOf(related)
+
+	if rowType.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a struct, got %s", rowType)
+	}
+
+	field, ok := rowType.FieldByName(name)
+	if !ok {
+		return fmt.Errorf("%s has no field %s", rowType, name)
+	}
+
+	allowed := []reflect.Type{relatedType, reflect.PtrTo(relatedType)}
+	if many {
+		allowed = []reflect.Type{
+			reflect.SliceOf(relatedType),
+			reflect.SliceOf(reflect.PtrTo(relatedType)),
+		}
+	}
+
+	if field.Type != allowed[0] && field.Type != allowed[1] {
+		return fmt.Errorf(
+			"%s.%s has to be a %s or a %s", rowType, name, allowed[0], allowed[1],
+		)
+	}
+	return nil
+}
+
+func setOne[R any](field reflect.Value, related *R) {
+	if field.Kind() == reflect.Pointer {
+		copied := *related
+		field.Set(reflect.ValueOf(&copied))
+	} else {
+		field.Set(reflect.ValueOf(*related))
+	}
+}
+
+func setMany[R any](field reflect.Value, related []R) {
+	if field.Type().Elem().Kind() != reflect.Pointer {
+		if related == nil {
+			related = []R{}
+		}
+		field.Set(reflect.ValueOf(related))
+		return
+	}
+
+	pointers := make([]*R, len(related))
+	for i := range related {
+		pointers[i] = &related[i]
+	}
+	field.Set(reflect.ValueOf(pointers))
+}
+
+// relationKeys returns the distinct, non-null values of a column in the rows, to
+// look up related rows with
+func relationKeys[T any](rows []T, column string) ([]any, error) {
+	seen := map[string]bool{}
+	keys := []any{}
+
+	for _, row := range rows {
+		field, err := readRowField(reflect.ValueOf(row), column)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := keyValue(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := normalizeKey(value)
+		if ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, value)
+		}
+	}
+	return keys, nil
+}
+
+// relationKey returns the normalized value of a column in a row. It is false if the
+// value is null
+func relationKey[T any](row T, column string) (string, bool, error) {
+	field, err := readRowField(reflect.ValueOf(row), column)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, err := keyValue(field.Interface())
+	if err != nil {
+		return "", false, err
+	}
+
+	key, ok := normalizeKey(value)
+	return key, ok, nil
+}
+
+// keyValue unwraps pointers and driver.Valuers (eg: sql.NullInt64)
+func keyValue(value any) (any, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		return valuer.Value()
+	}
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil, nil
+	}
+	return v.Interface(), nil
+}
+
+// normalizeKey turns a key into a string, so that keys of different types can be
+// matched (eg: an int field and an int64 from the driver, or []byte from MySQL). It
+// is false if the key is null
+func normalizeKey(key any) (string, bool) {
+	switch key := key.(type) {
+	case nil:
+		return "", false
+	case []byte:
+		return string(key), true
+	}
+	return fmt.Sprint(key), true
+}
@@ -0,0 +1,133 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreload(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	type Tag struct {
+		ID   int
+		Name string
+	}
+
+	type Todo struct {
+		ID       int
+		Title    string
+		AuthorID *int `db:"author_id"`
+
+		Author *User  `db:"-"`
+		Tags   []Tag  `db:"-"`
+		TagPtr []*Tag `db:"-"`
+	}
+
+	type Author struct {
+		ID   int
+		Name string
+
+		Todos []Todo `db:"-"`
+	}
+
+	UserTable := Table[User]{
+		Name:      "users",
+		Columns:   []string{"id", "name"},
+		RawSchema: "id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL",
+	}
+
+	TagTable := Table[Tag]{
+		Name:      "tags",
+		Columns:   []string{"id", "name"},
+		RawSchema: "id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL",
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "author_id"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			author_id INTEGER REFERENCES users(id)
+		`,
+		Relations: map[string]Relation[Todo]{
+			"Author": BelongsTo[Todo](UserTable, "author_id"),
+			"Tags":   ManyToMany[Todo](TagTable, "todo_tags", "todo_id", "tag_id"),
+			"TagPtr": ManyToMany[Todo](TagTable, "todo_tags", "todo_id", "tag_id"),
+		},
+	}
+
+	AuthorTable := Table[Author]{
+		Name:    "users",
+		Columns: []string{"id", "name"},
+		Relations: map[string]Relation[Author]{
+			"Todos": HasMany[Author](TodoTable, "author_id"),
+		},
+	}
+
+	require.NoError(t, UserTable.Create(db))
+	require.NoError(t, TagTable.Create(db))
+	require.NoError(t, TodoTable.Create(db))
+	db.MustExec("CREATE TABLE todo_tags (todo_id INTEGER, tag_id INTEGER)")
+
+	db.MustExec(`INSERT INTO users (name) VALUES ('Borat'), ('Azamat'), ('Pamela')`)
+	db.MustExec(`INSERT INTO tags (name) VALUES ('urgent'), ('travel')`)
+	db.MustExec(`INSERT INTO todos (title, author_id) VALUES
+		('find Pamela', 1), ('drive to California', 2), ('wrestle', 1), ('go home', NULL)
+	`)
+	db.MustExec(`INSERT INTO todo_tags VALUES (1, 2), (1, 1), (2, 2)`)
+
+	// When preloading with a query, there is one query per relation
+	todos, err := TodoTable.Select().OrderBy("id").Preload("Author", "Tags", "TagPtr").All(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 4)
+
+	require.Equal(t, &User{1, "Borat"}, todos[0].Author)
+	require.Equal(t, &User{2, "Azamat"}, todos[1].Author)
+	require.Equal(t, &User{1, "Borat"}, todos[2].Author)
+	require.Nil(t, todos[3].Author)
+
+	require.Equal(t, []Tag{{1, "urgent"}, {2, "travel"}}, todos[0].Tags)
+	require.Equal(t, []Tag{{2, "travel"}}, todos[1].Tags)
+	require.Equal(t, []Tag{}, todos[2].Tags)
+	require.Equal(t, []*Tag{{1, "urgent"}, {2, "travel"}}, todos[0].TagPtr)
+
+	// When preloading rows that were already fetched
+	authors, err := AuthorTable.Select().OrderBy("id").All(db)
+	require.NoError(t, err)
+
+	require.NoError(t, AuthorTable.Preload(db, authors, "Todos"))
+	require.Len(t, authors[0].Todos, 2)
+	require.Equal(t, "find Pamela", authors[0].Todos[0].Title)
+	require.Equal(t, "wrestle", authors[0].Todos[1].Title)
+	require.Len(t, authors[1].Todos, 1)
+	require.Equal(t, []Todo{}, authors[2].Todos)
+
+	// When there are no rows
+	require.NoError(t, AuthorTable.Preload(db, nil, "Todos"))
+
+	// When the relation doesn't exist
+	_, err = TodoTable.Select().Preload("Assignee").All(db)
+	require.EqualError(t, err, "todos has no relation Assignee")
+
+	// When the field can't hold the related rows
+	TodoTable.Relations["Title"] = BelongsTo[Todo](UserTable, "author_id")
+	_, err = TodoTable.Select().Preload("Title").All(db)
+	require.EqualError(
+		t,
+		err,
+		"preload Title: azamat.Todo.Title has to be a azamat.User or a *azamat.User",
+	)
+
+	// When the query isn't built from a Table
+	_, err = Select[Todo]("id").From("todos").Preload("Author").All(db)
+	require.Error(t, err)
+}
@@ -0,0 +1,603 @@
+package azamat
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Actions of a Table's REST handler
+const (
+	ActionList   = "list"
+	ActionGet    = "get"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// HandlerConfig configures Table.Handler
+type HandlerConfig[T any] struct {
+	// Runner runs the handler's queries, usually a *sqlx.DB
+	Runner Runner
+
+	// Params is the allowlist for filtering, sorting, and paginating the list (see
+	// ApplyParams)
+	Params QueryParams
+
+	// ReadFields are the columns that responses include. Defaults to the table's
+	// Columns
+	ReadFields []string
+
+	// WriteFields are the columns that requests can set. Defaults to the table's
+	// Columns, except for its ID column and the columns the table manages (timestamps,
+	// soft deletes, tenant, and version)
+	WriteFields []string
+
+	// Actions are the actions that are allowed. Defaults to all of them. Requests for
+	// the others get a 405
+	Actions []string
+
+	// MaxBodyBytes is the largest request body that creates and updates accept. Larger
+	// bodies get a 413. Defaults to 1MB
+	MaxBodyBytes int64
+
+	// Scope is optional. It returns a predicate that limits the rows a request can
+	// list, get, update, and delete (eg: to the current user's rows). Rows outside of
+	// it get a 404, and it is part of the update and delete statements themselves, so
+	// a row that leaves it in the meantime isn't written. Creates and updates have to
+	// leave the row inside of it, or they get a 403 and are rolled back. A Runner that
+	// is a *sqlx.Tx isn't rolled back by the handler, so its owner has to roll it back
+	Scope func(r *http.Request) sq.Sqlizer
+
+	// Authorize is optional. It is called before every action: with nil for list, the
+	// current row for get and delete, and the row as it would be after the write for
+	// create and update. If it returns an error, the request gets a 403 with the
+	// error's message
+	Authorize func(r *http.Request, action string, row *T) error
+
+	// OnError is optional. It is called with errors that get a 500, whose messages
+	// aren't sent to the client
+	OnError func(r *http.Request, err error)
+}
+
+// Handler returns an http.Handler that exposes the table as a JSON REST resource:
+//
+//	GET    /      lists rows, filtered, sorted, and paginated by query parameters
+//	POST   /      creates a row, and responds with it
+//	GET    /{id}  gets a row
+//	PATCH  /{id}  updates the fields in the body, and responds with the row
+//	PUT    /{id}  same as PATCH
+//	DELETE /{id}  deletes a row
+//
+// Rows are JSON objects keyed by column, and sql.Null* fields are JSON values or null.
+// Statements go through the table's builders,
+// so its timestamps, soft deletes, tenant isolation, and auditing apply, with the
+// request's context. Mount it with http.StripPrefix, eg:
+//
+//	mux.Handle("/todos/", http.StripPrefix("/todos", TodoTable.Handler(config)))
+func (t Table[T]) Handler(config HandlerConfig[T]) http.Handler {
+	if config.ReadFields == nil {
+		config.ReadFields = t.Columns
+	}
+
+	if config.WriteFields == nil {
+		managed := []string{
+			t.idColumn(),
+			t.CreatedAtColumn,
+			t.UpdatedAtColumn,
+			t.SoftDeleteColumn,
+			t.TenantColumn,
+			t.VersionColumn,
+		}
+
+		for _, column := range t.Columns {
+			if !contains(managed, column) {
+				config.WriteFields = append(config.WriteFields, column)
+			}
+		}
+	}
+
+	if config.Actions == nil {
+		config.Actions = []string{
+			ActionList, ActionGet, ActionCreate, ActionUpdate, ActionDelete,
+		}
+	}
+
+	if config.MaxBodyBytes == 0 {
+		config.MaxBodyBytes = 1 << 20
+	}
+
+	return restHandler[T]{table: t, config: config}
+}
+
+type restHandler[T any] struct {
+	table  Table[T]
+	config HandlerConfig[T]
+}
+
+// httpError is an error with the status code it should be responded to with
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e httpError) Error() string {
+	return e.message
+}
+
+var (
+	errNotFound         = httpError{http.StatusNotFound, "not found"}
+	errMethodNotAllowed = httpError{http.StatusMethodNotAllowed, "method not allowed"}
+	errOutOfScope       = httpError{http.StatusForbidden, "can't write rows outside of scope"}
+	errBodyTooLarge     = httpError{http.StatusRequestEntityTooLarge, "body is too large"}
+)
+
+func (h restHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action, id, err := h.route(r)
+	if err == nil && !contains(h.config.Actions, action) {
+		err = errMethodNotAllowed
+	}
+
+	if err == nil {
+		switch action {
+		case ActionList:
+			err = h.list(w, r)
+		case ActionCreate:
+			err = h.create(w, r)
+		case ActionGet:
+			err = h.get(w, r, id)
+		case ActionUpdate:
+			err = h.update(w, r, id)
+		case ActionDelete:
+			err = h.delete(w, r, id)
+		}
+	}
+
+	if err != nil {
+		h.fail(w, r, err)
+	}
+}
+
+// route returns the action that a request is for, and the ID of its row
+func (h restHandler[T]) route(r *http.Request) (string, int, error) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			return ActionList, 0, nil
+		case http.MethodPost:
+			return ActionCreate, 0, nil
+		}
+		return "", 0, errMethodNotAllowed
+	}
+
+	id, err := strconv.Atoi(path)
+	if err != nil {
+		return "", 0, errNotFound
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return ActionGet, id, nil
+	case http.MethodPatch, http.MethodPut:
+		return ActionUpdate, id, nil
+	case http.MethodDelete:
+		return ActionDelete, id, nil
+	}
+	return "", 0, errMethodNotAllowed
+}
+
+func (h restHandler[T]) fail(w http.ResponseWriter, r *http.Request, err error) {
+	var paramErrs ParamErrors
+	var httpErr httpError
+
+	switch {
+	case errors.As(err, &paramErrs):
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "invalid request",
+			"errors": paramErrs,
+		})
+
+	case errors.As(err, &httpErr):
+		writeJSON(w, httpErr.status, map[string]any{"error": httpErr.message})
+
+	default:
+		if h.config.OnError != nil {
+			h.config.OnError(r, err)
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]any{
+			"error": "internal error",
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// authorize calls the Authorize hook, if there is one
+func (h restHandler[T]) authorize(r *http.Request, action string, row *T) error {
+	if h.config.Authorize == nil {
+		return nil
+	}
+
+	if err := h.config.Authorize(r, action, row); err != nil {
+		return httpError{http.StatusForbidden, err.Error()}
+	}
+	return nil
+}
+
+func (h restHandler[T]) scoped(r *http.Request, query SelectBuilder[T]) SelectBuilder[T] {
+	if scope := h.scope(r); scope != nil {
+		query = query.Where(scope)
+	}
+	return query
+}
+
+// scope returns the request's Scope, or nil if there isn't one
+func (h restHandler[T]) scope(r *http.Request) sq.Sqlizer {
+	if h.config.Scope == nil {
+		return nil
+	}
+	return h.config.Scope(r)
+}
+
+// find gets the row with the given ID, if it is in the request's scope
+func (h restHandler[T]) find(r *http.Request, runner Runner, id int) (T, error) {
+	query := h.table.Select().Where(sq.Eq{h.table.idColumn(): id})
+
+	rows, err := h.scoped(r, query).AllContext(r.Context(), runner)
+	if err != nil {
+		var row T
+		return row, err
+	}
+
+	if len(rows) == 0 {
+		var row T
+		return row, errNotFound
+	}
+	return rows[0], nil
+}
+
+func (h restHandler[T]) list(w http.ResponseWriter, r *http.Request) error {
+	if err := h.authorize(r, ActionList, nil); err != nil {
+		return err
+	}
+
+	query, err := h.table.Select().ApplyParams(r.URL.Query(), h.config.Params)
+	if err != nil {
+		return err
+	}
+
+	rows, err := h.scoped(r, query).AllContext(r.Context(), h.config.Runner)
+	if err != nil {
+		return err
+	}
+
+	body := make([]map[string]any, len(rows))
+	for i := range rows {
+		if body[i], err = h.encode(rows[i]); err != nil {
+			return err
+		}
+	}
+
+	writeJSON(w, http.StatusOK, body)
+	return nil
+}
+
+func (h restHandler[T]) get(w http.ResponseWriter, r *http.Request, id int) error {
+	row, err := h.find(r, h.config.Runner, id)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authorize(r, ActionGet, &row); err != nil {
+		return err
+	}
+
+	return h.respond(w, http.StatusOK, row)
+}
+
+func (h restHandler[T]) create(w http.ResponseWriter, r *http.Request) error {
+	columns, values, err := h.decode(w, r)
+	if err != nil {
+		return err
+	}
+
+	var row T
+	if err := setRowFields(&row, columns, values); err != nil {
+		return err
+	}
+
+	if err := h.authorize(r, ActionCreate, &row); err != nil {
+		return err
+	}
+
+	err = inTransaction(h.config.Runner, func(runner Runner) error {
+		id, err := h.insert(r, runner, columns, values)
+		if err != nil {
+			return err
+		}
+
+		row, err = h.find(r, runner, id)
+		if err == errNotFound {
+			return errOutOfScope
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.respond(w, http.StatusCreated, row)
+}
+
+// insert inserts a row and returns its ID
+func (h restHandler[T]) insert(
+	r *http.Request, runner Runner, columns []string, values []any,
+) (int, error) {
+	insert := h.table.Insert().Columns(columns...).Values(values...)
+
+	// lib/pq doesn't support LastInsertId, but audited inserts get their IDs with
+	// RETURNING, so they have to be run as usual
+	if h.table.IsPostgres() && h.table.Audit == nil {
+		var id int
+		err := insert.Suffix("RETURNING " + h.table.idColumn()).
+			RunWith(withContext(runner)).
+			QueryRowContext(r.Context()).
+			Scan(&id)
+		return id, err
+	}
+
+	result, err := insert.RunContext(r.Context(), runner)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (h restHandler[T]) update(w http.ResponseWriter, r *http.Request, id int) error {
+	row, err := h.find(r, h.config.Runner, id)
+	if err != nil {
+		return err
+	}
+
+	columns, values, err := h.decode(w, r)
+	if err != nil {
+		return err
+	}
+
+	if err := setRowFields(&row, columns, values); err != nil {
+		return err
+	}
+
+	if err := h.authorize(r, ActionUpdate, &row); err != nil {
+		return err
+	}
+
+	err = inTransaction(h.config.Runner, func(runner Runner) error {
+		update := h.table.Update().Where(sq.Eq{h.table.idColumn(): id})
+		if scope := h.scope(r); scope != nil {
+			update = update.Where(scope)
+		}
+
+		for i, column := range columns {
+			update = update.Set(column, values[i])
+		}
+
+		result, err := update.RunContext(r.Context(), runner)
+		if err != nil {
+			return err
+		}
+
+		// MySQL doesn't count rows that matched but didn't change, so a row that isn't
+		// found afterwards tells whether the update matched it
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		row, err = h.find(r, runner, id)
+		if err == errNotFound {
+			if affected == 0 {
+				// The row left the scope (or was deleted) since it was read
+				return errNotFound
+			}
+			return errOutOfScope
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.respond(w, http.StatusOK, row)
+}
+
+func (h restHandler[T]) delete(w http.ResponseWriter, r *http.Request, id int) error {
+	row, err := h.find(r, h.config.Runner, id)
+	if err != nil {
+		return err
+	}
+
+	if err := h.authorize(r, ActionDelete, &row); err != nil {
+		return err
+	}
+
+	delete := h.table.Delete().Where(sq.Eq{h.table.idColumn(): id})
+	if scope := h.scope(r); scope != nil {
+		delete = delete.Where(scope)
+	}
+
+	result, err := delete.RunContext(r.Context(), h.config.Runner)
+	if err != nil {
+		return err
+	}
+
+	// The row may have left the scope (or been deleted) since it was read
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return errNotFound
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h restHandler[T]) respond(w http.ResponseWriter, status int, row T) error {
+	body, err := h.encode(row)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, status, body)
+	return nil
+}
+
+// encode turns a row into a JSON object with its ReadFields
+func (h restHandler[T]) encode(row T) (map[string]any, error) {
+	v := reflect.ValueOf(row)
+
+	body := make(map[string]any, len(h.config.ReadFields))
+	for _, column := range h.config.ReadFields {
+		field, err := readRowField(v, column)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case !isNullType(field.Type()):
+			body[column] = field.Interface()
+		case field.Field(1).Bool():
+			body[column] = field.Field(0).Interface()
+		default:
+			body[column] = nil
+		}
+	}
+	return body, nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// isNullType is true for nullable types like sql.NullString, which are a value and
+// whether it is Valid. In JSON, they are the value or null
+func isNullType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct &&
+		typ.NumField() == 2 &&
+		typ.Field(1).Name == "Valid" &&
+		typ.Field(1).Type.Kind() == reflect.Bool &&
+		typ.Implements(valuerType)
+}
+
+// decode reads the columns and values to write from a request's JSON body. Each
+// value is decoded into the Go type of its column's field
+func (h restHandler[T]) decode(
+	w http.ResponseWriter, r *http.Request,
+) ([]string, []any, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.config.MaxBodyBytes))
+	if err != nil {
+		return nil, nil, errBodyTooLarge
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, nil, httpError{http.StatusBadRequest, "body has to be a JSON object"}
+	}
+
+	if len(body) == 0 {
+		return nil, nil, httpError{http.StatusBadRequest, "body has no fields"}
+	}
+
+	columns := make([]string, 0, len(body))
+	for column := range body {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var row T
+	rowType := reflect.TypeOf(row)
+
+	var errs ParamErrors
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		if !contains(h.config.WriteFields, column) {
+			errs = append(errs, ParamError{column, "can't be written"})
+			continue
+		}
+
+		typ := columnType(rowType, column)
+		if typ == nil {
+			typ = reflect.TypeOf((*any)(nil)).Elem()
+		}
+
+		value, err := decodeValue(body[column], typ)
+		if err != nil {
+			errs = append(errs, ParamError{column, err.Error()})
+			continue
+		}
+		values[i] = value
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return columns, values, nil
+}
+
+// decodeValue decodes a JSON value into a value of the given type
+func decodeValue(data json.RawMessage, typ reflect.Type) (any, error) {
+	value := reflect.New(typ).Elem()
+
+	target := value
+	if isNullType(typ) {
+		if string(data) == "null" {
+			return value.Interface(), nil
+		}
+		value.Field(1).SetBool(true)
+		target = value.Field(0)
+	}
+
+	if err := json.Unmarshal(data, target.Addr().Interface()); err != nil {
+		return nil, fmt.Errorf("has to be a %s", target.Type())
+	}
+	return value.Interface(), nil
+}
+
+// setRowFields sets the fields of a row to decoded values. Columns that the row
+// doesn't have a field for are skipped
+func setRowFields[T any](row *T, columns []string, values []any) error {
+	v := reflect.ValueOf(row).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i, column := range columns {
+		if _, err := fieldIndex(v.Type(), column); err != nil || values[i] == nil {
+			continue
+		}
+
+		field, err := rowField(v, column)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(values[i]))
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package azamat
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type Todo struct {
+		ID      int
+		Title   string
+		OwnerID int `db:"owner_id"`
+		Secret  string
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "owner_id", "secret"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			owner_id INTEGER NOT NULL DEFAULT 0,
+			secret TEXT NOT NULL DEFAULT ''
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	db.MustExec(`INSERT INTO todos (title, owner_id, secret) VALUES
+		('assist Borat', 1, 'a'),
+		('find Pamela', 1, 'b'),
+		('wrestle', 2, 'c')
+	`)
+
+	// Requests are made by the user in the X-User header
+	handler := TodoTable.Handler(HandlerConfig[Todo]{
+		Runner: db,
+		Params: QueryParams{
+			Filters:     map[string][]string{"title": {OpEq, OpLike}},
+			Sorts:       []string{"id"},
+			DefaultSort: "id",
+			MaxLimit:    10,
+		},
+		ReadFields:  []string{"id", "title", "owner_id"},
+		WriteFields: []string{"title", "owner_id"},
+		Scope: func(r *http.Request) sq.Sqlizer {
+			return sq.Eq{"owner_id": r.Header.Get("X-User")}
+		},
+		Authorize: func(r *http.Request, action string, row *Todo) error {
+			if action == ActionDelete && row.Title == "assist Borat" {
+				return errors.New("Borat needs assistance")
+			}
+			if action != ActionList && row.Title == "kidnap Pamela" {
+				return errors.New("that is illegal")
+			}
+			return nil
+		},
+	})
+
+	server := httptest.NewServer(http.StripPrefix("/todos", handler))
+	defer server.Close()
+
+	request := func(method, path, body string) (int, any) {
+		req, err := http.NewRequest(method, server.URL+"/todos"+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-User", "1")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		var decoded any
+		json.NewDecoder(res.Body).Decode(&decoded)
+		return res.StatusCode, decoded
+	}
+
+	todo := func(id int, title string) map[string]any {
+		return map[string]any{"id": float64(id), "title": title, "owner_id": float64(1)}
+	}
+
+	// When listing, only the user's rows and the ReadFields are included
+	status, body := request("GET", "/", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, []any{todo(1, "assist Borat"), todo(2, "find Pamela")}, body)
+
+	// When listing with query parameters
+	status, body = request("GET", "?title[like]=find%25&limit=5", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, []any{todo(2, "find Pamela")}, body)
+
+	status, body = request("GET", "?secret=a&limit=50", "")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, map[string]any{
+		"error": "invalid request",
+		"errors": []any{
+			map[string]any{"param": "secret", "message": "can't filter on secret"},
+			map[string]any{"param": "limit", "message": "can't be more than 10"},
+		},
+	}, body)
+
+	// When getting a row
+	status, body = request("GET", "/2", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, todo(2, "find Pamela"), body)
+
+	// When getting a row outside of the scope, or that doesn't exist
+	status, _ = request("GET", "/3", "")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = request("GET", "/nope", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	// When creating a row
+	status, body = request("POST", "/", `{"title": "go home", "owner_id": 1}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, todo(4, "go home"), body)
+
+	// When writing fields that can't be written, or with the wrong types
+	status, body = request("POST", "/", `{"id": 9, "secret": "x", "title": 5}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, map[string]any{
+		"error": "invalid request",
+		"errors": []any{
+			map[string]any{"param": "id", "message": "can't be written"},
+			map[string]any{"param": "secret", "message": "can't be written"},
+			map[string]any{"param": "title", "message": "has to be a string"},
+		},
+	}, body)
+
+	status, _ = request("POST", "/", `[]`)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// When updating a row
+	status, body = request("PATCH", "/4", `{"title": "stay"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, todo(4, "stay"), body)
+
+	status, _ = request("PUT", "/3", `{"title": "stolen"}`)
+	require.Equal(t, http.StatusNotFound, status)
+
+	// When a write would put the row outside of the scope, it is rolled back
+	status, body = request("POST", "/", `{"title": "give away", "owner_id": 2}`)
+	require.Equal(t, http.StatusForbidden, status)
+	require.Equal(t, map[string]any{"error": "can't write rows outside of scope"}, body)
+
+	status, _ = request("PATCH", "/4", `{"owner_id": 2}`)
+	require.Equal(t, http.StatusForbidden, status)
+
+	status, body = request("GET", "/4", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, todo(4, "stay"), body)
+
+	var count int
+	require.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM todos"))
+	require.Equal(t, 4, count)
+
+	// When Authorize rejects what is being written
+	status, body = request("POST", "/", `{"title": "kidnap Pamela", "owner_id": 1}`)
+	require.Equal(t, http.StatusForbidden, status)
+	require.Equal(t, map[string]any{"error": "that is illegal"}, body)
+
+	status, _ = request("PATCH", "/4", `{"title": "kidnap Pamela"}`)
+	require.Equal(t, http.StatusForbidden, status)
+
+	// When deleting a row
+	status, _ = request("DELETE", "/4", "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = request("GET", "/4", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	// When the request isn't authorized
+	status, body = request("DELETE", "/1", "")
+	require.Equal(t, http.StatusForbidden, status)
+	require.Equal(t, map[string]any{"error": "Borat needs assistance"}, body)
+
+	// When the method isn't allowed
+	status, _ = request("POST", "/1", "{}")
+	require.Equal(t, http.StatusMethodNotAllowed, status)
+}
+
+func TestHandlerActions(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	TodoTable := Table[Todo]{
+		Name:      "todos",
+		Columns:   []string{"id", "title"},
+		RawSchema: "id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL",
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+	db.MustExec(`INSERT INTO todos (title) VALUES ('assist Borat')`)
+
+	var errs []error
+	handler := TodoTable.Handler(HandlerConfig[Todo]{
+		Runner:  db,
+		Actions: []string{ActionList, ActionGet},
+		OnError: func(r *http.Request, err error) { errs = append(errs, err) },
+	})
+
+	// When the action is allowed
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"id": 1, "title": "assist Borat"}`, rec.Body.String())
+
+	// When the action isn't allowed
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/1", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/99", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// When the query fails, the error isn't sent to the client
+	db.MustExec("DROP TABLE todos")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.JSONEq(t, `{"error": "internal error"}`, rec.Body.String())
+	require.Len(t, errs, 1)
+}
+
+func TestHandlerWriteFields(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type Todo struct {
+		ID        int
+		Title     string
+		TenantID  int       `db:"tenant_id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	TodoTable := Table[Todo]{
+		Name:            "todos",
+		Columns:         []string{"id", "title", "tenant_id", "created_at"},
+		TenantColumn:    "tenant_id",
+		CreatedAtColumn: "created_at",
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			tenant_id INTEGER NOT NULL,
+			created_at DATETIME NOT NULL
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	handler := TodoTable.Handler(HandlerConfig[Todo]{Runner: db})
+	withTenant := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), 1)))
+	})
+
+	// When writing columns that the table manages, the request is rejected
+	rec := httptest.NewRecorder()
+	body := `{"title": "assist Borat", "tenant_id": 2, "created_at": "2022-04-20T00:00:00Z"}`
+	withTenant.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.JSONEq(t, `{
+		"error": "invalid request",
+		"errors": [
+			{"param": "created_at", "message": "can't be written"},
+			{"param": "tenant_id", "message": "can't be written"}
+		]
+	}`, rec.Body.String())
+
+	// When only writing the other columns
+	rec = httptest.NewRecorder()
+	body = `{"title": "assist Borat"}`
+	withTenant.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	todo, err := TodoTable.GetByIDContext(WithTenant(context.Background(), 1), db, 1)
+	require.NoError(t, err)
+	require.Equal(t, "assist Borat", todo.Title)
+	require.Equal(t, 1, todo.TenantID)
+}
+
+func TestHandlerScopedWrites(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type Todo struct {
+		ID      int
+		Title   string
+		OwnerID int `db:"owner_id"`
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "owner_id"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			owner_id INTEGER NOT NULL
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+	db.MustExec(`INSERT INTO todos (title, owner_id) VALUES ('wrestle', 1), ('wed', 1)`)
+
+	// The row is given away right after the handler reads it, before it is written
+	handler := TodoTable.Handler(HandlerConfig[Todo]{
+		Runner: db,
+		Scope: func(r *http.Request) sq.Sqlizer {
+			return sq.Eq{"owner_id": 1}
+		},
+		Authorize: func(r *http.Request, action string, row *Todo) error {
+			if action == ActionUpdate || action == ActionDelete {
+				db.MustExec(`UPDATE todos SET owner_id = 2 WHERE id = ?`, row.ID)
+			}
+			return nil
+		},
+	})
+
+	// When the row leaves the scope before it is updated, it isn't updated
+	rec := httptest.NewRecorder()
+	body := `{"title": "wrestle bear"}`
+	handler.ServeHTTP(rec, httptest.NewRequest("PATCH", "/1", strings.NewReader(body)))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// When the row leaves the scope before it is deleted, it isn't deleted
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/2", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	todos, err := TodoTable.GetAll(db)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{{1, "wrestle", 2}, {2, "wed", 2}}, todos)
+}
+
+func TestHandlerBody(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	db.SetMaxOpenConns(1)
+
+	type Todo struct {
+		ID    int
+		Title string
+		Notes sql.NullString
+		DueAt sql.NullTime `db:"due_at"`
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "notes", "due_at"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			notes TEXT,
+			due_at DATETIME
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	handler := TodoTable.Handler(HandlerConfig[Todo]{Runner: db, MaxBodyBytes: 100})
+
+	// When nullable fields are set, they are plain JSON values
+	rec := httptest.NewRecorder()
+	body := `{"title": "wrestle", "notes": "bear", "due_at": "2022-04-20T00:00:00Z"}`
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{
+		"id": 1, "title": "wrestle", "notes": "bear", "due_at": "2022-04-20T00:00:00Z"
+	}`, rec.Body.String())
+
+	// When they are null
+	rec = httptest.NewRecorder()
+	body = `{"notes": null, "due_at": null}`
+	handler.ServeHTTP(rec, httptest.NewRequest("PATCH", "/1", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(
+		t, `{"id": 1, "title": "wrestle", "notes": null, "due_at": null}`, rec.Body.String(),
+	)
+
+	// When they have the wrong type
+	rec = httptest.NewRecorder()
+	body = `{"notes": 1}`
+	handler.ServeHTTP(rec, httptest.NewRequest("PATCH", "/1", strings.NewReader(body)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "has to be a string")
+
+	// When the body is too large
+	rec = httptest.NewRecorder()
+	body = `{"title": "` + strings.Repeat("a", 100) + `"}`
+	handler.ServeHTTP(rec, httptest.NewRequest("PATCH", "/1", strings.NewReader(body)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
@@ -0,0 +1,117 @@
+package azamat
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// mapper maps columns to struct fields the same way sqlx does when it scans rows
+// (ie: using db tags, falling back to the lowercased field name)
+var mapper = reflectx.NewMapperFunc("db", sqlx.NameMapper)
+
+// rowField returns the field of a row struct that holds the given column. Nil
+// pointers on the way to the field are allocated, so it can be set
+func rowField(row reflect.Value, column string) (reflect.Value, error) {
+	row = reflect.Indirect(row)
+	index, err := fieldIndex(row.Type(), column)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return reflectx.FieldByIndexes(row, index), nil
+}
+
+// readRowField is like rowField, but it leaves the row as it is (so nil pointers
+// stay nil), and the row doesn't have to be addressable. If the field is in an
+// embedded struct that is a nil pointer, it is unset, so its zero value is returned
+func readRowField(row reflect.Value, column string) (reflect.Value, error) {
+	row = reflect.Indirect(row)
+	index, err := fieldIndex(row.Type(), column)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	field := row
+	for i, n := range index {
+		if i > 0 && field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				return reflect.Zero(row.Type().FieldByIndex(index).Type), nil
+			}
+			field = field.Elem()
+		}
+		field = field.Field(n)
+	}
+	return field, nil
+}
+
+func fieldIndex(row reflect.Type, column string) ([]int, error) {
+	if row.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct, got %s", row)
+	}
+
+	field, ok := mapper.TypeMap(row).Names[column]
+	if !ok {
+		return nil, fmt.Errorf("%s has no field for column %s", row, column)
+	}
+
+	return field.Index, nil
+}
+
+// UpdateRow updates the row with the same ID as the given struct, setting the table's
+// Columns to the struct's values. Columns that the table manages (timestamps, the
+// tenant, and soft deletes) aren't set from the struct, and soft deleted rows aren't
+// updated. If the table has a VersionColumn, the update only succeeds if the row
+// hasn't been changed (or soft deleted) since it was read
+func (t Table[T]) UpdateRow(runner Runner, row *T) error {
+	return t.UpdateRowContext(context.Background(), runner, row)
+}
+
+func (t Table[T]) UpdateRowContext(
+	ctx context.Context, runner Runner, row *T,
+) error {
+	v := reflect.ValueOf(row).Elem()
+
+	idField, err := readRowField(v, t.idColumn())
+	if err != nil {
+		return err
+	}
+	id := idField.Interface()
+
+	skip := []string{
+		t.idColumn(),
+		t.VersionColumn,
+		t.CreatedAtColumn,
+		t.UpdatedAtColumn,
+		t.TenantColumn,
+		t.SoftDeleteColumn,
+	}
+
+	update := t.Update().Where(sq.Eq{t.idColumn(): id})
+	if t.SoftDeleteColumn != "" {
+		// A soft deleted row is gone, so it can't be updated (or undeleted) this way
+		update = update.Where(sq.Eq{t.SoftDeleteColumn: nil})
+	}
+	for _, column := range t.Columns {
+		if contains(skip, column) {
+			continue
+		}
+
+		field, err := readRowField(v, column)
+		if err != nil {
+			return err
+		}
+		update = update.Set(column, field.Interface())
+	}
+
+	if t.VersionColumn == "" {
+		_, err := update.RunContext(ctx, runner)
+		return err
+	}
+
+	return t.updateVersioned(ctx, runner, update, v, id)
+}
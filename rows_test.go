@@ -0,0 +1,183 @@
+package azamat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableUpdateRow(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID   int `db:"user_id"`
+		Name string
+	}
+
+	UserTable := Table[User]{
+		Name:     "users",
+		Columns:  []string{"user_id", "name"},
+		IDColumn: "user_id",
+	}
+
+	db.MustExec(`CREATE TABLE users (
+		user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	)`)
+
+	db.MustExec(`INSERT INTO users (name) VALUES (?), (?)`, "Borat", "Azamat")
+
+	// When updating a row...
+	user, err := UserTable.GetByID(db, 2)
+	require.NoError(t, err)
+
+	user.Name = "Pamela"
+	err = UserTable.UpdateRow(db, &user)
+	require.NoError(t, err)
+
+	// Make sure only that row was updated
+	users, err := UserTable.GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "Borat", users[0].Name)
+	assert.Equal(t, "Pamela", users[1].Name)
+
+	// When the struct is missing a column...
+	BadTable := UserTable
+	BadTable.Columns = []string{"user_id", "name", "email"}
+	err = BadTable.UpdateRow(db, &user)
+	require.Error(t, err)
+}
+
+func TestTableUpdateRowTimestamps(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID        int
+		Name      string
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	now := time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	UserTable := Table[User]{
+		Name:            "users",
+		Columns:         []string{"id", "name", "created_at", "updated_at"},
+		CreatedAtColumn: "created_at",
+		UpdatedAtColumn: "updated_at",
+		Clock:           func() time.Time { return now },
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		`,
+	}
+
+	require.NoError(t, UserTable.Create(db))
+
+	_, err := UserTable.Insert().Columns("name").Values("Borat").Run(db)
+	require.NoError(t, err)
+
+	user, err := UserTable.GetByID(db, 1)
+	require.NoError(t, err)
+
+	// When updating a row, updated_at advances and created_at is left alone
+	now = now.Add(time.Hour)
+	user.Name = "Azamat"
+	user.CreatedAt = time.Time{}
+
+	err = UserTable.UpdateRow(db, &user)
+	require.NoError(t, err)
+
+	user, err = UserTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Azamat", user.Name)
+	assert.True(t, now.Add(-time.Hour).Equal(user.CreatedAt))
+	assert.True(t, now.Equal(user.UpdatedAt))
+}
+
+func TestTableUpdateRowSoftDeleted(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Version   int
+		DeletedAt *time.Time `db:"deleted_at"`
+	}
+
+	TodoTable := Table[Todo]{
+		Name:             "todos",
+		Columns:          []string{"id", "title", "version", "deleted_at"},
+		VersionColumn:    "version",
+		SoftDeleteColumn: "deleted_at",
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			version INTEGER NOT NULL DEFAULT 1,
+			deleted_at DATETIME
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+	db.MustExec(`INSERT INTO todos (title) VALUES (?)`, "wrestle")
+
+	todo, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+
+	_, err = TodoTable.Delete().Where("id = ?", 1).Run(db)
+	require.NoError(t, err)
+
+	// When updating a row that was soft deleted after it was read...
+	todo.Title = "wrestle bear"
+	err = TodoTable.UpdateRow(db, &todo)
+	require.Equal(t, ErrStaleObject{Table: "todos", ID: 1, Version: 1}, err)
+
+	// Make sure it is still deleted, and wasn't changed
+	deleted, err := TodoTable.WithDeleted().GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "wrestle", deleted.Title)
+	assert.Equal(t, 1, deleted.Version)
+	assert.NotNil(t, deleted.DeletedAt)
+}
+
+func TestTableUpdateRowEmbedded(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Details struct {
+		Notes string
+	}
+
+	type Todo struct {
+		ID    int
+		Title string
+		*Details
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "notes"},
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			notes TEXT NOT NULL DEFAULT ''
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+	db.MustExec(`INSERT INTO todos (title) VALUES (?)`, "wrestle")
+
+	// When the struct has a nil embedded pointer, it is read without being allocated
+	todo := Todo{ID: 1, Title: "wrestle bear"}
+	require.NoError(t, TodoTable.UpdateRow(db, &todo))
+	assert.Nil(t, todo.Details)
+
+	updated, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "wrestle bear", updated.Title)
+}
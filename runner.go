@@ -1,6 +1,12 @@
 package azamat
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
 
 // Runner can be a *sqlx.DB or a *sqlx.Tx. This allows us to write code that can be
 // run as a standalone statement or as part of a transaction
@@ -9,3 +15,67 @@ type Runner interface {
 	Select(dest any, query string, args ...any) error
 	Get(dest any, query string, args ...any) error
 }
+
+// contextRunner is a Runner that can pass a context to the database, like *sqlx.DB
+// and *sqlx.Tx
+type contextRunner interface {
+	Runner
+	sqlx.ExtContext
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// withContext returns the runner as a contextRunner. A Runner that can't pass a context
+// to the database (eg: a test double) runs statements without it
+func withContext(runner Runner) contextRunner {
+	if r, ok := runner.(contextRunner); ok {
+		return r
+	}
+	return noContext{runner}
+}
+
+// noContext is a Runner that ignores the context it is given
+type noContext struct {
+	Runner
+}
+
+func (r noContext) ExecContext(
+	_ context.Context, query string, args ...any,
+) (sql.Result, error) {
+	return r.Exec(query, args...)
+}
+
+func (r noContext) QueryContext(
+	_ context.Context, query string, args ...any,
+) (*sql.Rows, error) {
+	return r.Query(query, args...)
+}
+
+func (r noContext) QueryxContext(
+	_ context.Context, query string, args ...any,
+) (*sqlx.Rows, error) {
+	return r.Queryx(query, args...)
+}
+
+func (r noContext) QueryRowxContext(
+	_ context.Context, query string, args ...any,
+) *sqlx.Row {
+	return r.QueryRowx(query, args...)
+}
+
+// QueryRowContext lets squirrel's QueryRowContext run on the runner
+func (r noContext) QueryRowContext(
+	_ context.Context, query string, args ...any,
+) sq.RowScanner {
+	return r.QueryRowx(query, args...)
+}
+
+func (r noContext) SelectContext(
+	_ context.Context, dest any, query string, args ...any,
+) error {
+	return r.Select(dest, query, args...)
+}
+
+func (r noContext) GetContext(_ context.Context, dest any, query string, args ...any) error {
+	return r.Get(dest, query, args...)
+}
@@ -0,0 +1,54 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// plainRunner only has the methods of Runner, like a test double would
+type plainRunner struct {
+	Runner
+}
+
+func TestRunnerWithoutContext(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+	runner := plainRunner{db}
+
+	type Todo struct {
+		ID    int
+		Title string
+	}
+
+	TodoTable := Table[Todo]{
+		Name:             "todos",
+		Columns:          []string{"id", "title"},
+		SoftDeleteColumn: "deleted_at",
+		RawSchema: `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			deleted_at DATETIME
+		`,
+	}
+
+	require.NoError(t, TodoTable.Create(db))
+
+	// When running statements with a Runner that can't take a context
+	_, err := TodoTable.Insert().Columns("title").Values("wrestle").Run(runner)
+	require.NoError(t, err)
+
+	_, err = TodoTable.Update().Set("title", "wrestle bear").Where("id = ?", 1).Run(runner)
+	require.NoError(t, err)
+
+	todos, err := TodoTable.GetAll(runner)
+	require.NoError(t, err)
+	require.Equal(t, []Todo{{1, "wrestle bear"}}, todos)
+
+	_, err = TodoTable.Delete().Where("id = ?", 1).Run(runner)
+	require.NoError(t, err)
+
+	todos, err = TodoTable.GetAll(runner)
+	require.NoError(t, err)
+	require.Empty(t, todos)
+}
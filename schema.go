@@ -0,0 +1,532 @@
+package azamat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Schema is a structured description of a table's columns, constraints, and indexes.
+// Unlike RawSchema, it can be rendered for any dialect, validated, and diffed
+type Schema struct {
+	Columns     []ColumnDef
+	PrimaryKey  []string
+	Uniques     []UniqueDef
+	ForeignKeys []ForeignKeyDef
+	Checks      []CheckDef
+	Indexes     []IndexDef
+}
+
+// ColumnDef describes a column of a table
+type ColumnDef struct {
+	Name     string
+	Type     string
+	Nullable bool
+
+	// Default is a SQL expression, so string literals have to be quoted
+	Default string
+
+	// AutoIncrement columns are filled in by the db. An AutoIncrement column has to be
+	// the table's primary key
+	AutoIncrement bool
+}
+
+// UniqueDef is a unique constraint
+type UniqueDef struct {
+	Name    string
+	Columns []string
+}
+
+// ForeignKeyDef is a foreign key constraint
+type ForeignKeyDef struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	OnUpdate   string
+}
+
+// CheckDef is a check constraint
+type CheckDef struct {
+	Name string
+	Expr string
+}
+
+// IndexDef is an index on a table
+type IndexDef struct {
+	Name    string
+	Columns []string
+	Unique  bool
+
+	// Expressions are indexed after the Columns, eg: "lower(email)". MySQL only
+	// supports them since 8.0.13
+	Expressions []string
+
+	// Where makes the index partial, so that it only covers the rows that match it.
+	// MySQL doesn't support partial indexes
+	Where string
+}
+
+// Column returns the column with the given name, if there is one
+func (s Schema) Column(name string) (ColumnDef, bool) {
+	for _, c := range s.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return ColumnDef{}, false
+}
+
+// columnNames returns the names of the schema's columns, in order
+func (s Schema) columnNames() []string {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// managedSchema is the schema of a table that azamat manages (eg: the outbox): an
+// autoincrementing id, followed by the given columns. It is rendered for whichever
+// dialect the table is created on
+func managedSchema(columns ...ColumnDef) *Schema {
+	id := ColumnDef{Name: "id", Type: TypeBigInt, AutoIncrement: true}
+	return &Schema{
+		Columns:    append([]ColumnDef{id}, columns...),
+		PrimaryKey: []string{"id"},
+	}
+}
+
+// Validate checks that the schema is complete and that its constraints and indexes
+// refer to columns that exist
+func (s Schema) Validate() error {
+	if len(s.Columns) == 0 {
+		return fmt.Errorf("schema has no columns")
+	}
+
+	seen := map[string]bool{}
+	for _, column := range s.Columns {
+		if column.Name == "" {
+			return fmt.Errorf("column has no name")
+		}
+
+		if column.Type == "" {
+			return fmt.Errorf("column %s has no type", column.Name)
+		}
+
+		if seen[strings.ToLower(column.Name)] {
+			return fmt.Errorf("column %s is declared more than once", column.Name)
+		}
+		seen[strings.ToLower(column.Name)] = true
+
+		if column.AutoIncrement &&
+			(len(s.PrimaryKey) != 1 || !strings.EqualFold(s.PrimaryKey[0], column.Name)) {
+			return fmt.Errorf(
+				"column %s autoincrements, so it has to be the primary key", column.Name,
+			)
+		}
+	}
+
+	hasColumns := func(what string, columns []string) error {
+		if len(columns) == 0 {
+			return fmt.Errorf("%s has no columns", what)
+		}
+
+		for _, column := range columns {
+			if !seen[strings.ToLower(column)] {
+				return fmt.Errorf("%s refers to unknown column %s", what, column)
+			}
+		}
+		return nil
+	}
+
+	if len(s.PrimaryKey) > 0 {
+		if err := hasColumns("primary key", s.PrimaryKey); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range s.Uniques {
+		if err := hasColumns("unique constraint", u.Columns); err != nil {
+			return err
+		}
+	}
+
+	for _, fk := range s.ForeignKeys {
+		if err := hasColumns("foreign key", fk.Columns); err != nil {
+			return err
+		}
+
+		if fk.RefTable == "" || len(fk.RefColumns) != len(fk.Columns) {
+			return fmt.Errorf(
+				"foreign key on %s has to reference as many columns as it has",
+				columnList(fk.Columns),
+			)
+		}
+	}
+
+	for _, check := range s.Checks {
+		if check.Expr == "" {
+			return fmt.Errorf("check constraint has no expression")
+		}
+	}
+
+	for _, index := range s.Indexes {
+		if err := index.validate(seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (index IndexDef) validate(columns map[string]bool) error {
+	if index.Name == "" {
+		return fmt.Errorf("index on %s has no name", columnList(index.Columns))
+	}
+
+	if len(index.Columns) == 0 && len(index.Expressions) == 0 {
+		return fmt.Errorf("index %s has no columns or expressions", index.Name)
+	}
+
+	// columns is nil when the table's columns aren't known
+	for _, column := range index.Columns {
+		if columns != nil && !columns[strings.ToLower(column)] {
+			return fmt.Errorf("index %s refers to unknown column %s", index.Name, column)
+		}
+	}
+	return nil
+}
+
+// ParseSchema parses the column definitions and table constraints of a CREATE TABLE
+// statement, ie: what goes in a Table's RawSchema. It understands the common subset of
+// SQL that is shared by SQLite, Postgres, and MySQL
+func ParseSchema(raw string) (Schema, error) {
+	var schema Schema
+
+	for _, def := range splitTopLevel(raw, ',') {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+
+		var err error
+		if index, ok := parseIndex(def); ok {
+			schema.Indexes = append(schema.Indexes, index)
+		} else if isTableConstraint(def) {
+			err = schema.parseTableConstraint(def)
+		} else {
+			err = schema.parseColumn(def)
+		}
+
+		if err != nil {
+			return schema, err
+		}
+	}
+
+	// Primary key columns can't be null, even when they are declared by a table
+	// constraint
+	for i, column := range schema.Columns {
+		if containsFold(schema.PrimaryKey, column.Name) {
+			schema.Columns[i].Nullable = false
+		}
+	}
+
+	return schema, nil
+}
+
+// quotedName matches a name, which can be quoted with double quotes or (on MySQL)
+// backticks
+const quotedName = "[\"`]?\\w+[\"`]?"
+
+var (
+	constraintName = regexp.MustCompile(`(?is)^CONSTRAINT\s+(` + quotedName + `)\s+(.*)$`)
+	primaryKey     = regexp.MustCompile(`(?is)^PRIMARY\s+KEY\s*\((.*)\)$`)
+	unique         = regexp.MustCompile(
+		`(?is)^UNIQUE(?:\s+(?:KEY|INDEX))?(?:\s+(` + quotedName + `))?\s*\((.*)\)$`,
+	)
+	foreignKey = regexp.MustCompile(
+		`(?is)^FOREIGN\s+KEY\s*\(([^)]*)\)\s*REFERENCES\s+(` + quotedName +
+			`)\s*\(([^)]*)\)(.*)$`,
+	)
+	check      = regexp.MustCompile(`(?is)^CHECK\s*\((.*)\)$`)
+	references = regexp.MustCompile(
+		`(?is)^REFERENCES\s+(` + quotedName + `)\s*\(([^)]*)\)`,
+	)
+	onAction = regexp.MustCompile(
+		`(?is)ON\s+(DELETE|UPDATE)\s+(CASCADE|RESTRICT|NO\s+ACTION|SET\s+NULL|SET\s+DEFAULT)`,
+	)
+	tableConstraint = regexp.MustCompile(
+		`(?i)^(CONSTRAINT|PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY|CHECK)\b`,
+	)
+
+	// MySQL declares indexes in the table, eg: KEY todos_due (due_at)
+	mysqlIndex = regexp.MustCompile(
+		`(?is)^(?:KEY|INDEX)(?:\s+(` + quotedName + `))?\s*\((.*)\)(?:\s+USING\s+\w+)?$`,
+	)
+	prefixLength = regexp.MustCompile(`\s*\(\d+\)$`)
+)
+
+func isTableConstraint(def string) bool {
+	return tableConstraint.MatchString(def)
+}
+
+// parseIndex parses a MySQL index definition. A column named key (eg: "key
+// VARCHAR(255)") looks like one, so a name that is a column type isn't an index
+func parseIndex(def string) (IndexDef, bool) {
+	m := mysqlIndex.FindStringSubmatch(def)
+	if m == nil {
+		return IndexDef{}, false
+	}
+	if _, ok := defaultGoTypes[typeFamily(m[1])]; ok {
+		return IndexDef{}, false
+	}
+
+	return IndexDef{Name: unquote(m[1]), Columns: indexColumns(m[2])}, true
+}
+
+// indexColumns splits the columns of a MySQL index, dropping their prefix lengths
+// (eg: "title(10)") and sort orders
+func indexColumns(s string) (columns []string) {
+	for _, column := range splitTopLevel(s, ',') {
+		fields := strings.Fields(column)
+		if len(fields) == 0 {
+			continue
+		}
+		column = prefixLength.ReplaceAllString(fields[0], "")
+		columns = append(columns, unquote(column))
+	}
+	return
+}
+
+func (s *Schema) parseTableConstraint(def string) error {
+	name := ""
+	if m := constraintName.FindStringSubmatch(def); m != nil {
+		name, def = unquote(m[1]), strings.TrimSpace(m[2])
+	}
+
+	if m := primaryKey.FindStringSubmatch(def); m != nil {
+		s.PrimaryKey = splitNames(m[1])
+		return nil
+	}
+
+	if m := unique.FindStringSubmatch(def); m != nil {
+		// MySQL names unique keys after the keyword, eg: UNIQUE KEY todos_title (title)
+		if name == "" {
+			name = unquote(m[1])
+		}
+		s.Uniques = append(s.Uniques, UniqueDef{Name: name, Columns: indexColumns(m[2])})
+		return nil
+	}
+
+	if m := foreignKey.FindStringSubmatch(def); m != nil {
+		fk := ForeignKeyDef{
+			Name:       name,
+			Columns:    splitNames(m[1]),
+			RefTable:   unquote(m[2]),
+			RefColumns: splitNames(m[3]),
+		}
+		fk.parseActions(m[4])
+		s.ForeignKeys = append(s.ForeignKeys, fk)
+		return nil
+	}
+
+	if m := check.FindStringSubmatch(def); m != nil {
+		s.Checks = append(s.Checks, CheckDef{Name: name, Expr: strings.TrimSpace(m[1])})
+		return nil
+	}
+
+	return fmt.Errorf("unknown table constraint: %s", def)
+}
+
+func (fk *ForeignKeyDef) parseActions(s string) {
+	for _, m := range onAction.FindAllStringSubmatch(s, -1) {
+		action := foreignKeyAction(m[2])
+		if strings.EqualFold(m[1], "DELETE") {
+			fk.OnDelete = action
+		} else {
+			fk.OnUpdate = action
+		}
+	}
+}
+
+// columnKeywords are the keywords that can follow a column's type
+var columnKeywords = []string{
+	"NOT", "NULL", "PRIMARY", "UNIQUE", "DEFAULT", "REFERENCES", "CHECK",
+	"CONSTRAINT", "AUTOINCREMENT", "AUTO_INCREMENT", "COLLATE", "GENERATED",
+}
+
+func (s *Schema) parseColumn(def string) error {
+	tokens := tokenize(def)
+	if len(tokens) == 0 {
+		return fmt.Errorf("empty column definition")
+	}
+
+	column := ColumnDef{Name: unquote(tokens[0]), Nullable: true}
+
+	// The type is everything up until the first constraint keyword
+	i := 1
+	var typ []string
+	for ; i < len(tokens) && !isKeyword(tokens[i], columnKeywords); i++ {
+		typ = append(typ, tokens[i])
+	}
+	column.Type = strings.Join(typ, " ")
+
+	for ; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "NOT":
+			if i+1 < len(tokens) && strings.EqualFold(tokens[i+1], "NULL") {
+				column.Nullable = false
+				i++
+			}
+
+		case "PRIMARY":
+			s.PrimaryKey = []string{column.Name}
+			column.Nullable = false
+			i++ // KEY
+
+		case "AUTOINCREMENT", "AUTO_INCREMENT":
+			column.AutoIncrement = true
+
+		case "UNIQUE":
+			s.Uniques = append(s.Uniques, UniqueDef{Columns: []string{column.Name}})
+
+		case "DEFAULT":
+			// MySQL spells out DEFAULT NULL, which is the same as no default
+			if i+1 < len(tokens) {
+				if !strings.EqualFold(tokens[i+1], "NULL") {
+					column.Default = tokens[i+1]
+				}
+				i++
+			}
+
+		case "CHECK":
+			if i+1 < len(tokens) {
+				expr := strings.TrimSuffix(strings.TrimPrefix(tokens[i+1], "("), ")")
+				s.Checks = append(s.Checks, CheckDef{Expr: strings.TrimSpace(expr)})
+				i++
+			}
+
+		case "REFERENCES":
+			rest := strings.Join(tokens[i:], " ")
+			m := references.FindStringSubmatch(rest)
+			if m == nil {
+				return fmt.Errorf("invalid reference: %s", rest)
+			}
+
+			fk := ForeignKeyDef{
+				Columns:    []string{column.Name},
+				RefTable:   unquote(m[1]),
+				RefColumns: splitNames(m[2]),
+			}
+			fk.parseActions(rest)
+			s.ForeignKeys = append(s.ForeignKeys, fk)
+			i = len(tokens)
+		}
+	}
+
+	s.Columns = append(s.Columns, column)
+	return nil
+}
+
+// tokenize splits a definition on whitespace, keeping parenthesized groups and quoted
+// strings together. A parenthesized group that directly follows a word (eg: the length
+// in VARCHAR(255)) is kept with the word
+func tokenize(def string) (tokens []string) {
+	var current strings.Builder
+	depth := 0
+	var quote rune
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range def {
+		switch {
+		case quote != 0:
+			current.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			current.WriteRune(r)
+		case r == '(':
+			depth++
+			current.WriteRune(r)
+		case r == ')':
+			depth--
+			current.WriteRune(r)
+		case depth == 0 && (r == ' ' || r == '\t' || r == '\n' || r == '\r'):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// splitTopLevel splits a string on sep, ignoring separators inside of parentheses or
+// quotes
+func splitTopLevel(s string, sep rune) (parts []string) {
+	depth := 0
+	var quote rune
+	start := 0
+
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func splitNames(s string) (names []string) {
+	for _, name := range strings.Split(s, ",") {
+		if name = unquote(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+func unquote(name string) string {
+	return strings.Trim(name, "\"`")
+}
+
+func isKeyword(token string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.EqualFold(token, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
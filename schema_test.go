@@ -0,0 +1,174 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema(`
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title VARCHAR(255) NOT NULL UNIQUE,
+		status TEXT NOT NULL DEFAULT 'todo' CHECK (status IN ('todo', 'done')),
+		author_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP,
+		CONSTRAINT todos_author_title UNIQUE (author_id, title),
+		FOREIGN KEY (created_at) REFERENCES days (day) ON UPDATE SET NULL
+	`)
+	require.NoError(t, err)
+
+	expectedColumns := []ColumnDef{
+		{Name: "id", Type: "INTEGER", AutoIncrement: true},
+		{Name: "title", Type: "VARCHAR(255)"},
+		{Name: "status", Type: "TEXT", Default: "'todo'"},
+		{Name: "author_id", Type: "INTEGER", Nullable: true},
+		{Name: "created_at", Type: "TIMESTAMP", Nullable: true},
+	}
+
+	assert.Equal(t, expectedColumns, schema.Columns)
+	assert.Equal(t, []string{"id"}, schema.PrimaryKey)
+
+	expectedUniques := []UniqueDef{
+		{Columns: []string{"title"}},
+		{Name: "todos_author_title", Columns: []string{"author_id", "title"}},
+	}
+	assert.Equal(t, expectedUniques, schema.Uniques)
+
+	expectedForeignKeys := []ForeignKeyDef{
+		{
+			Columns:    []string{"author_id"},
+			RefTable:   "users",
+			RefColumns: []string{"id"},
+			OnDelete:   "CASCADE",
+		},
+		{
+			Columns:    []string{"created_at"},
+			RefTable:   "days",
+			RefColumns: []string{"day"},
+			OnUpdate:   "SET NULL",
+		},
+	}
+	assert.Equal(t, expectedForeignKeys, schema.ForeignKeys)
+
+	expectedChecks := []CheckDef{{Expr: "status IN ('todo', 'done')"}}
+	assert.Equal(t, expectedChecks, schema.Checks)
+
+	// When the primary key is a table constraint...
+	schema, err = ParseSchema(`
+		user_id INTEGER,
+		group_id INTEGER,
+		PRIMARY KEY (user_id, group_id)
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user_id", "group_id"}, schema.PrimaryKey)
+
+	// (primary key columns can't be null)
+	for _, column := range schema.Columns {
+		assert.False(t, column.Nullable)
+	}
+
+	// When a table constraint isn't understood...
+	_, err = ParseSchema("id INTEGER, CONSTRAINT nope")
+	require.Error(t, err)
+}
+
+func TestParseSchemaMySQL(t *testing.T) {
+	// What SHOW CREATE TABLE prints for a MySQL table
+	schema, err := ParseSchema(
+		"`id` bigint NOT NULL AUTO_INCREMENT,\n" +
+			"`title` varchar(255) NOT NULL,\n" +
+			"`author_id` bigint DEFAULT NULL,\n" +
+			"`due_at` datetime DEFAULT NULL,\n" +
+			"`key` varchar(64) NOT NULL,\n" +
+			"PRIMARY KEY (`id`),\n" +
+			"UNIQUE KEY `todos_author_title` (`author_id`,`title`),\n" +
+			"KEY `todos_due` (`due_at`),\n" +
+			"INDEX `todos_title` (`title`(10) DESC) USING BTREE,\n" +
+			"CONSTRAINT `todos_author` FOREIGN KEY (`author_id`) REFERENCES `users` (`id`) " +
+			"ON DELETE CASCADE",
+	)
+	require.NoError(t, err)
+
+	expectedColumns := []ColumnDef{
+		{Name: "id", Type: "bigint", AutoIncrement: true},
+		{Name: "title", Type: "varchar(255)"},
+		{Name: "author_id", Type: "bigint", Nullable: true},
+		{Name: "due_at", Type: "datetime", Nullable: true},
+		{Name: "key", Type: "varchar(64)"},
+	}
+	assert.Equal(t, expectedColumns, schema.Columns)
+	assert.Equal(t, []string{"id"}, schema.PrimaryKey)
+
+	expectedUniques := []UniqueDef{
+		{Name: "todos_author_title", Columns: []string{"author_id", "title"}},
+	}
+	assert.Equal(t, expectedUniques, schema.Uniques)
+
+	expectedIndexes := []IndexDef{
+		{Name: "todos_due", Columns: []string{"due_at"}},
+		{Name: "todos_title", Columns: []string{"title"}},
+	}
+	assert.Equal(t, expectedIndexes, schema.Indexes)
+
+	expectedForeignKeys := []ForeignKeyDef{
+		{
+			Name:       "todos_author",
+			Columns:    []string{"author_id"},
+			RefTable:   "users",
+			RefColumns: []string{"id"},
+			OnDelete:   "CASCADE",
+		},
+	}
+	assert.Equal(t, expectedForeignKeys, schema.ForeignKeys)
+	require.NoError(t, schema.Validate())
+
+	// When a column is named key, it isn't mistaken for an index
+	schema, err = ParseSchema("key VARCHAR(255), unique_code TEXT UNIQUE")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key", "unique_code"}, schema.columnNames())
+	assert.Empty(t, schema.Indexes)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	valid := func() Schema {
+		return Schema{
+			Columns: []ColumnDef{
+				{Name: "id", Type: TypeInteger, AutoIncrement: true},
+				{Name: "title", Type: TypeText},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes:    []IndexDef{{Name: "todos_title", Columns: []string{"title"}}},
+		}
+	}
+
+	require.NoError(t, valid().Validate())
+
+	// When a column has no type...
+	schema := valid()
+	schema.Columns[1].Type = ""
+	require.Error(t, schema.Validate())
+
+	// When a column is declared twice...
+	schema = valid()
+	schema.Columns = append(schema.Columns, ColumnDef{Name: "Title", Type: TypeText})
+	require.Error(t, schema.Validate())
+
+	// When an autoincrement column isn't the primary key...
+	schema = valid()
+	schema.PrimaryKey = nil
+	require.Error(t, schema.Validate())
+
+	// When an index refers to a column that doesn't exist...
+	schema = valid()
+	schema.Indexes[0].Columns = []string{"nope"}
+	require.Error(t, schema.Validate())
+
+	// When a foreign key is missing referenced columns...
+	schema = valid()
+	schema.ForeignKeys = []ForeignKeyDef{
+		{Columns: []string{"title"}, RefTable: "titles"},
+	}
+	require.Error(t, schema.Validate())
+}
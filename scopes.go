@@ -0,0 +1,70 @@
+package azamat
+
+import sq "github.com/Masterminds/squirrel"
+
+// Scope is a reusable query fragment, such as "only active users." Scopes can be
+// applied to a SelectBuilder with Scopes
+type Scope[T any] func(SelectBuilder[T]) SelectBuilder[T]
+
+// Scopes applies the given scopes to the builder, in order
+func (b SelectBuilder[T]) Scopes(scopes ...Scope[T]) SelectBuilder[T] {
+	for _, scope := range scopes {
+		b = scope(b)
+	}
+	return b
+}
+
+// Unscoped returns a copy of the table that doesn't apply its DefaultScopes
+func (t Table[T]) Unscoped() Table[T] {
+	t.unscoped = true
+	return t
+}
+
+// scopes returns the DefaultScopes that apply to the table
+func (t Table[T]) scopes() []sq.Sqlizer {
+	if t.unscoped {
+		return nil
+	}
+	return t.DefaultScopes
+}
+
+// scoped narrows down a select so it only includes the rows the table should read
+func (t Table[T]) scoped(query sq.SelectBuilder) sq.SelectBuilder {
+	for _, scope := range t.scopes() {
+		query = query.Where(scope)
+	}
+
+	if scope := t.softDeleteScope(); scope != nil {
+		query = query.Where(scope)
+	}
+
+	return query
+}
+
+// basicScoped is like scoped, but for queries that are built without a context. For
+// tenant-scoped tables, the query is made to fail since the tenant is unknown
+func (t Table[T]) basicScoped(query sq.SelectBuilder) sq.SelectBuilder {
+	if t.TenantColumn != "" {
+		query = query.Where(errPredicate{ErrNoTenant})
+	}
+
+	return t.scoped(query)
+}
+
+// Unscoped returns a copy of the view that doesn't apply its DefaultScopes
+func (v View[T]) Unscoped() View[T] {
+	v.unscoped = true
+	return v
+}
+
+// scoped narrows down the view's query with its DefaultScopes
+func (v View[T]) scoped(query sq.SelectBuilder) sq.SelectBuilder {
+	if v.unscoped {
+		return query
+	}
+
+	for _, scope := range v.DefaultScopes {
+		query = query.Where(scope)
+	}
+	return query
+}
@@ -0,0 +1,185 @@
+package azamat
+
+import (
+	"fmt"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableDefaultScopes(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID     int
+		Name   string
+		Active bool
+	}
+
+	UserTable := Table[User]{
+		Name:          "users",
+		Columns:       []string{"id", "name", "active"},
+		DefaultScopes: []sq.Sqlizer{sq.Eq{"active": true}},
+	}
+
+	db.MustExec(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		active BOOLEAN NOT NULL
+	)`)
+
+	// Create some users
+	db.MustExec(
+		`INSERT INTO users (name, active) VALUES (?, 1), (?, 0), (?, 1)`,
+		"Borat", "Azamat", "Pamela",
+	)
+
+	// Make sure reads only include active users
+	users, err := UserTable.GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "Borat", users[0].Name)
+	assert.Equal(t, "Pamela", users[1].Name)
+
+	_, err = UserTable.GetByID(db, 2)
+	require.Error(t, err)
+
+	// Make sure updates only affect active users
+	_, err = UserTable.Update().Set("name", "Kazakh").Run(db)
+	require.NoError(t, err)
+
+	user, err := UserTable.Unscoped().GetByID(db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Azamat", user.Name)
+
+	// Make sure deletes only affect active users
+	_, err = UserTable.Delete().Run(db)
+	require.NoError(t, err)
+
+	users, err = UserTable.Unscoped().GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "Azamat", users[0].Name)
+}
+
+func TestSelectScopes(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		Completed bool
+	}
+
+	TodoTable := Table[Todo]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "completed"},
+	}
+
+	incomplete := func(b SelectBuilder[Todo]) SelectBuilder[Todo] {
+		return b.Where(sq.Eq{"completed": false})
+	}
+
+	newestFirst := func(b SelectBuilder[Todo]) SelectBuilder[Todo] {
+		return b.OrderBy("id DESC")
+	}
+
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		completed BOOLEAN NOT NULL
+	)`)
+
+	// Create some todos
+	db.MustExec(
+		`INSERT INTO todos (title, completed) VALUES (?, 0), (?, 1), (?, 0)`,
+		"assist Borat", "find Pamela", "buy bear food",
+	)
+
+	// When applying multiple scopes...
+	todos, err := TodoTable.Select().Scopes(incomplete, newestFirst).All(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	assert.Equal(t, "buy bear food", todos[0].Title)
+	assert.Equal(t, "assist Borat", todos[1].Title)
+}
+
+func TestViewDefaultScopes(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	type Todo struct {
+		ID     int
+		Title  string
+		Author string
+	}
+
+	type TodoRow struct {
+		ID       int
+		Title    string
+		AuthorID int `db:"authorID"`
+	}
+
+	UserTable := Table[User]{
+		Name:    "users",
+		Columns: []string{"id", "name"},
+	}
+
+	TodoTable := Table[TodoRow]{
+		Name:    "todos",
+		Columns: []string{"id", "title", "authorID"},
+	}
+
+	TodoView := View[Todo]{
+		IDFrom: TodoTable,
+		Query: func() sq.SelectBuilder {
+			join := fmt.Sprintf(
+				"%s ON %s.id = %s.authorID", UserTable, UserTable, TodoTable,
+			)
+
+			return TodoTable.
+				BasicSelect("id", "title").
+				Columns("name AS author").
+				Join(join)
+		},
+		DefaultScopes: []sq.Sqlizer{sq.Eq{"users.name": "Borat"}},
+	}
+
+	db.MustExec(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL
+	)`)
+
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		authorID INTEGER NOT NULL
+	)`)
+
+	db.MustExec(`INSERT INTO users (name) VALUES (?), (?)`, "Azamat", "Borat")
+	db.MustExec(
+		`INSERT INTO todos (title, authorID) VALUES (?, 2), (?, 1)`,
+		"find Pamela", "assist Borat",
+	)
+
+	// Make sure reads are scoped
+	todos, err := TodoView.GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "find Pamela", todos[0].Title)
+
+	_, err = TodoView.GetByID(db, 2)
+	require.Error(t, err)
+
+	// When opting out of the default scopes...
+	todos, err = TodoView.Unscoped().GetByIDs(db, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+}
@@ -1,6 +1,7 @@
 package azamat
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -9,6 +10,20 @@ import (
 
 type SelectBuilder[T any] struct {
 	sq.SelectBuilder
+	hooks []hook[sq.SelectBuilder]
+	lock  lockClause
+
+	// dialect is the dialect of the runner the query will be run with, if known
+	dialect Dialect
+
+	// ref is the name (or alias) of the query's Table, which qualifies the columns that
+	// ApplyParams adds
+	ref string
+
+	// preloads are the relations to load along with the rows, using the preloader of
+	// the query's Table
+	preloads  []string
+	preloader func(ctx context.Context, runner Runner, rows []T, relations ...string) error
 }
 
 func Select[T any](columns ...string) SelectBuilder[T] {
@@ -18,29 +33,54 @@ func Select[T any](columns ...string) SelectBuilder[T] {
 }
 
 func (b SelectBuilder[T]) All(runner Runner) ([]T, error) {
-	sql, args, err := b.ToSql()
+	return b.AllContext(context.Background(), runner)
+}
+
+// AllContext is like All but passes the context to the database and to any behavior
+// that the query's Table adds (eg: tenant isolation)
+func (b SelectBuilder[T]) AllContext(ctx context.Context, runner Runner) ([]T, error) {
+	b.dialect = DialectOf(runner)
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sql, args, err := built.ToSql()
 	if err != nil {
 		return nil, err
 	}
 
 	var rows []T
-	err = runner.Select(&rows, sql, args...)
-	return rows, err
+	if err := withContext(runner).SelectContext(ctx, &rows, sql, args...); err != nil {
+		return nil, err
+	}
+
+	if len(b.preloads) > 0 {
+		if b.preloader == nil {
+			return nil, fmt.Errorf("only queries built from a Table can preload")
+		}
+
+		if err := b.preloader(ctx, runner, rows, b.preloads...); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
 }
 
 func (b SelectBuilder[T]) Only(runner Runner) (T, error) {
+	return b.OnlyContext(context.Background(), runner)
+}
+
+// OnlyContext is like Only but passes the context to the database and to any
+// behavior that the query's Table adds (eg: tenant isolation)
+func (b SelectBuilder[T]) OnlyContext(ctx context.Context, runner Runner) (T, error) {
 	var row T
 
-	sql, args, err := b.ToSql()
+	rows, err := b.AllContext(ctx, runner)
 	if err != nil {
 		return row, err
 	}
 
-	var rows []T
-	if err := runner.Select(&rows, sql, args...); err != nil {
-		return row, err
-	}
-
 	if len(rows) == 0 {
 		return row, fmt.Errorf("none found")
 	}
@@ -52,6 +92,36 @@ func (b SelectBuilder[T]) Only(runner Runner) (T, error) {
 	return rows[0], nil
 }
 
+// Preload loads the given relations of the rows that the query returns (see
+// Table.Preload)
+func (b SelectBuilder[T]) Preload(relations ...string) SelectBuilder[T] {
+	b.preloads = append(append([]string{}, b.preloads...), relations...)
+	return b
+}
+
+func (b SelectBuilder[T]) build(ctx context.Context) (sq.SelectBuilder, error) {
+	built, err := applyHooks(ctx, b.SelectBuilder, b.hooks)
+	if err != nil {
+		return built, err
+	}
+	return b.applyLock(built), nil
+}
+
+// downgrade returns the underlying squirrel builder. If the query can't be built
+// without a context, the returned builder fails when it is built
+func (b SelectBuilder[T]) downgrade() sq.SelectBuilder {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return b.SelectBuilder.Where(errPredicate{err})
+	}
+	return built
+}
+
+// ToSql builds the query into a SQL string and bound args
+func (b SelectBuilder[T]) ToSql() (string, []interface{}, error) {
+	return b.downgrade().ToSql()
+}
+
 func (b SelectBuilder[T]) PlaceholderFormat(f sq.PlaceholderFormat) SelectBuilder[T] {
 	b.SelectBuilder = b.SelectBuilder.PlaceholderFormat(f)
 	return b
@@ -59,11 +129,40 @@ func (b SelectBuilder[T]) PlaceholderFormat(f sq.PlaceholderFormat) SelectBuilde
 
 func (b SelectBuilder[T]) RunWith(runner sq.BaseRunner) SelectBuilder[T] {
 	b.SelectBuilder = b.SelectBuilder.RunWith(runner)
+	b.dialect = DialectOf(runner)
 	return b
 }
 
 func (b SelectBuilder[T]) Exec() (sql.Result, error) {
-	return b.SelectBuilder.Exec()
+	return b.downgrade().Exec()
+}
+
+func (b SelectBuilder[T]) Query() (*sql.Rows, error) {
+	return b.downgrade().Query()
+}
+
+func (b SelectBuilder[T]) QueryRow() sq.RowScanner {
+	return b.downgrade().QueryRow()
+}
+
+func (b SelectBuilder[T]) Scan(dest ...interface{}) error {
+	return b.downgrade().Scan(dest...)
+}
+
+func (b SelectBuilder[T]) QueryContext(ctx context.Context) (*sql.Rows, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.QueryContext(ctx)
+}
+
+func (b SelectBuilder[T]) QueryRowContext(ctx context.Context) sq.RowScanner {
+	built, err := b.build(ctx)
+	if err != nil {
+		return errRow{err}
+	}
+	return built.QueryRowContext(ctx)
 }
 
 func (b SelectBuilder[T]) Prefix(sql string, args ...interface{}) SelectBuilder[T] {
@@ -88,14 +187,14 @@ func (b SelectBuilder[T]) Options(options ...string) SelectBuilder[T] {
 
 // Downgrades the builder to a regular squirrel builder
 func (b SelectBuilder[T]) Columns(columns ...string) sq.SelectBuilder {
-	return b.SelectBuilder.Columns(columns...)
+	return b.downgrade().Columns(columns...)
 }
 
 // Downgrades the builder to a regular squirrel builder
 func (b SelectBuilder[T]) Column(
 	column interface{}, args ...interface{},
 ) sq.SelectBuilder {
-	return b.SelectBuilder.Column(column, args...)
+	return b.downgrade().Column(column, args...)
 }
 
 func (b SelectBuilder[T]) From(from string) SelectBuilder[T] {
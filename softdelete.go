@@ -0,0 +1,69 @@
+package azamat
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type deletedScope int
+
+const (
+	excludeDeleted deletedScope = iota
+	includeDeleted
+	onlyDeleted
+)
+
+// WithDeleted returns a copy of the table whose reads include soft deleted rows
+func (t Table[T]) WithDeleted() Table[T] {
+	t.deleted = includeDeleted
+	return t
+}
+
+// OnlyDeleted returns a copy of the table whose reads only include soft deleted rows
+func (t Table[T]) OnlyDeleted() Table[T] {
+	t.deleted = onlyDeleted
+	return t
+}
+
+// Restore returns a buildable Update statement that un-deletes soft deleted rows.
+// Like Update, it affects every deleted row unless you add a Where
+func (t Table[T]) Restore() UpdateBuilder {
+	t.alias = ""
+	column := t.softDeleteColumn()
+	return t.Update().Set(t.SoftDeleteColumn, nil).Where(sq.NotEq{column: nil})
+}
+
+// softDeleteColumn returns the SoftDeleteColumn qualified with the table name or alias
+func (t Table[T]) softDeleteColumn() string {
+	return fmt.Sprintf("%s.%s", t.ref(), t.SoftDeleteColumn)
+}
+
+// softDeleteScope returns the predicate that filters reads by whether rows have been
+// soft deleted. It returns nil when reads shouldn't be filtered
+func (t Table[T]) softDeleteScope() sq.Sqlizer {
+	if t.SoftDeleteColumn == "" {
+		return nil
+	}
+
+	switch t.deleted {
+	case excludeDeleted:
+		return sq.Eq{t.softDeleteColumn(): nil}
+	case onlyDeleted:
+		return sq.NotEq{t.softDeleteColumn(): nil}
+	}
+
+	return nil
+}
+
+// softDelete turns a delete into an update that marks the rows as deleted. Rows
+// that are already deleted are left alone so they keep their original deletion time
+func (t Table[T]) softDelete(d sq.DeleteBuilder) sq.UpdateBuilder {
+	update := deleteToUpdate(d, t.Name).
+		Set(t.SoftDeleteColumn, t.now()).
+		Where(sq.Eq{t.softDeleteColumn(): nil})
+
+	update, _ = t.updateTimestamps(context.Background(), update)
+	return update
+}
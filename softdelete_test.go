@@ -0,0 +1,117 @@
+package azamat
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableSoftDelete(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		DeletedAt sql.NullTime `db:"deleted_at"`
+	}
+
+	now := time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	TodoTable := Table[Todo]{
+		Name:             "todos",
+		Columns:          []string{"id", "title", "deleted_at"},
+		SoftDeleteColumn: "deleted_at",
+		Clock:            func() time.Time { return now },
+	}
+
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		deleted_at DATETIME
+	)`)
+
+	// Create some todos
+	todo1, todo2, todo3 := "assist Borat", "find Pamela", "buy bear food"
+	db.MustExec(
+		`INSERT INTO todos (title) VALUES (?), (?), (?)`, todo1, todo2, todo3,
+	)
+
+	// When deleting an entry...
+	_, err := TodoTable.Delete().Where("id = ?", 2).Run(db)
+	require.NoError(t, err)
+
+	// Make sure the entry still exists, but is marked as deleted
+	var rows []Todo
+	err = db.Select(&rows, "SELECT * FROM todos WHERE id = 2")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.True(t, rows[0].DeletedAt.Valid)
+	assert.True(t, now.Equal(rows[0].DeletedAt.Time))
+
+	// Make sure reads exclude the deleted entry
+	todos, err := TodoTable.GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	assert.Equal(t, todo1, todos[0].Title)
+	assert.Equal(t, todo3, todos[1].Title)
+
+	_, err = TodoTable.GetByID(db, 2)
+	require.Error(t, err)
+
+	todos, err = TodoTable.GetByIDs(db, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+
+	// When explicitly including deleted entries...
+	todos, err = TodoTable.WithDeleted().GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 3)
+
+	todos, err = TodoTable.OnlyDeleted().GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, todo2, todos[0].Title)
+
+	// When deleting an entry that is already deleted...
+	TodoTable.Clock = func() time.Time { return now.Add(time.Hour) }
+	result, err := TodoTable.Delete().Where("id = ?", 2).Run(db)
+	require.NoError(t, err)
+
+	// Make sure it keeps its original deletion time
+	affected, _ := result.RowsAffected()
+	assert.Zero(t, affected)
+
+	todo, err := TodoTable.WithDeleted().GetByID(db, 2)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(todo.DeletedAt.Time))
+
+	// When restoring an entry...
+	_, err = TodoTable.Restore().Where("id = ?", 2).Run(db)
+	require.NoError(t, err)
+
+	todo, err = TodoTable.GetByID(db, 2)
+	require.NoError(t, err)
+	assert.False(t, todo.DeletedAt.Valid)
+
+	// When hard deleting an entry...
+	_, err = TodoTable.HardDelete().Where("id = ?", 3).Run(db)
+	require.NoError(t, err)
+
+	// Make sure it's actually gone
+	todos, err = TodoTable.WithDeleted().GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+
+	// Make sure the soft delete is part of the generated SQL
+	sql, _, err := TodoTable.Delete().Where("id = ?", 1).ToSql()
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"UPDATE todos SET deleted_at = ? WHERE id = ? AND todos.deleted_at IS NULL",
+		sql,
+	)
+}
@@ -1,7 +1,9 @@
 package azamat
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
@@ -14,6 +16,57 @@ type Table[T any] struct {
 	RawSchema string
 	IDColumn  string
 	Postgres  bool
+
+	// Schema is optional. It describes the table in a structured way, so that it can
+	// be created on any dialect. When set, it is used instead of RawSchema
+	Schema *Schema
+
+	// Indexes are optional. They are created along with the table, on top of the
+	// indexes in its Schema
+	Indexes []IndexDef
+
+	// CreatedAtColumn and UpdatedAtColumn are optional. When set, inserts and updates
+	// built from the table fill them in automatically (unless the caller sets them)
+	CreatedAtColumn string
+	UpdatedAtColumn string
+
+	// Clock is used to get the current time for automatic timestamps. It defaults to
+	// time.Now but can be swapped out to make tests deterministic
+	Clock func() time.Time
+
+	// SoftDeleteColumn is optional. When set, Delete marks rows as deleted by setting
+	// this column to the current time, and reads exclude rows that have been deleted
+	SoftDeleteColumn string
+
+	// DefaultScopes are predicates that are applied to every Select, Update, and
+	// Delete built from the table. Use Unscoped to opt out of them
+	DefaultScopes []sq.Sqlizer
+
+	// TenantColumn is optional. When set, the table is tenant-scoped: every statement
+	// has to be run with a context that carries a tenant (see WithTenant), and it only
+	// reads, writes, and deletes that tenant's rows
+	TenantColumn string
+
+	// VersionColumn is optional. When set, UpdateRow uses it for optimistic locking:
+	// the row is only updated if its version hasn't changed since it was read
+	VersionColumn string
+
+	// Audit is optional. When set, inserts, updates, and deletes built from the table
+	// are recorded in the audit log
+	Audit *AuditLog
+
+	// Relations are optional. They map the names of struct fields to the relationships
+	// that fill them in when they are preloaded (see Preload)
+	Relations map[string]Relation[T]
+
+	// deleted controls whether reads include soft deleted rows
+	deleted deletedScope
+
+	// unscoped is set when DefaultScopes should not be applied
+	unscoped bool
+
+	// alias is the name that selects refer to the table by (see As)
+	alias string
 }
 
 func (t Table[T]) String() string {
@@ -24,39 +77,77 @@ func (t Table[T]) IsPostgres() bool {
 	return t.Postgres || Postgres
 }
 
+// dialect returns the table's dialect, if it is known from its definition
+func (t Table[T]) dialect() Dialect {
+	if t.IsPostgres() {
+		return DialectPostgres
+	}
+	return ""
+}
+
 func (t Table[T]) GetAll(runner Runner) ([]T, error) {
-	return t.Select().All(runner)
+	return t.GetAllContext(context.Background(), runner)
 }
 
 func (t Table[T]) GetByID(runner Runner, id int) (T, error) {
-	idCol := "id"
-	if t.IDColumn != "" {
-		idCol = t.IDColumn
-	}
-
-	return t.Select().Where(sq.Eq{idCol: id}).Only(runner)
+	return t.GetByIDContext(context.Background(), runner, id)
 }
 
 func (t Table[T]) GetByIDs(runner Runner, ids ...int) ([]T, error) {
+	return t.GetByIDsContext(context.Background(), runner, ids...)
+}
+
+func (t Table[T]) GetAllContext(ctx context.Context, runner Runner) ([]T, error) {
+	return t.Select().AllContext(ctx, runner)
+}
+
+func (t Table[T]) GetByIDContext(
+	ctx context.Context, runner Runner, id int,
+) (T, error) {
+	return t.Select().Where(sq.Eq{t.idColumn(): id}).OnlyContext(ctx, runner)
+}
+
+func (t Table[T]) GetByIDsContext(
+	ctx context.Context, runner Runner, ids ...int,
+) ([]T, error) {
+	return t.Select().Where(sq.Eq{t.idColumn(): ids}).AllContext(ctx, runner)
+}
+
+func (t Table[T]) idColumn() string {
 	idCol := "id"
 	if t.IDColumn != "" {
 		idCol = t.IDColumn
 	}
-
-	return t.Select().Where(sq.Eq{idCol: ids}).All(runner)
+	return idCol
 }
 
+// Create creates the table and its indexes
 func (t Table[T]) Create(db *sqlx.DB) error {
-	createTable := fmt.Sprintf("CREATE TABLE %s (%s)", t.Name, t.RawSchema)
-	_, err := db.Exec(createTable)
-	return err
+	if err := t.createTable(db, false); err != nil {
+		return err
+	}
+	return t.createIndexes(db, false)
 }
 
+// CreateIfNotExists creates the table and any of its indexes that don't exist yet
 func (t Table[T]) CreateIfNotExists(db *sqlx.DB) error {
-	createTable := fmt.Sprintf(
-		"CREATE TABLE IF NOT EXISTS %s (%s)", t.Name, t.RawSchema,
-	)
-	_, err := db.Exec(createTable)
+	if err := t.createTable(db, true); err != nil {
+		return err
+	}
+	return t.CreateIndexes(db)
+}
+
+func (t Table[T]) createTable(db *sqlx.DB, ifNotExists bool) error {
+	if t.Schema != nil {
+		return t.createFromSchema(db, ifNotExists)
+	}
+
+	create := "CREATE TABLE"
+	if ifNotExists {
+		create = "CREATE TABLE IF NOT EXISTS"
+	}
+
+	_, err := db.Exec(fmt.Sprintf("%s %s (%s)", create, t.Name, t.RawSchema))
 	return err
 }
 
@@ -72,55 +163,119 @@ func PrefixColumns(prefix string, columns []string) (cols []string) {
 // Select returns a buildable Select query that is bound to a specific table name. If
 // no columns are provided, it gets all columns specified by the table
 func (t Table[T]) Select() SelectBuilder[T] {
+	query := sq.Select(PrefixColumns(t.ref(), t.Columns)...).From(t.from())
 	if t.IsPostgres() {
-		return SelectBuilder[T]{
-			psql.Select(PrefixColumns(t.Name, t.Columns)...).From(t.Name),
-		}
+		query = psql.Select(PrefixColumns(t.ref(), t.Columns)...).From(t.from())
 	}
 
 	return SelectBuilder[T]{
-		sq.Select(PrefixColumns(t.Name, t.Columns)...).From(t.Name),
+		SelectBuilder: t.scoped(query),
+		hooks:         []hook[sq.SelectBuilder]{t.selectTenant},
+		dialect:       t.dialect(),
+		ref:           t.ref(),
+		preloader:     t.PreloadContext,
 	}
 }
 
 // BasicSelect is like Select but downgrades the builder to be non-generic. This is
-// useful when you don't want to select all columns of the table.
+// useful when you don't want to select all columns of the table. Since the builder
+// doesn't have a context, it can't be run for tenant-scoped tables.
 func (t Table[T]) BasicSelect(columns ...string) sq.SelectBuilder {
-	actualColumns := PrefixColumns(t.Name, columns)
+	actualColumns := PrefixColumns(t.ref(), columns)
 	if len(columns) == 0 {
-		actualColumns = PrefixColumns(t.Name, t.Columns)
+		actualColumns = PrefixColumns(t.ref(), t.Columns)
 	}
 
 	if t.IsPostgres() {
-		return psql.Select(actualColumns...).From(t.Name)
+		return t.basicScoped(psql.Select(actualColumns...).From(t.from()))
 	}
 
-	return sq.Select(actualColumns...).From(t.Name)
+	return t.basicScoped(sq.Select(actualColumns...).From(t.from()))
 }
 
 // Insert returns a buildable Insert statement that is bound to a specific table name
 func (t Table[T]) Insert() InsertBuilder {
+	t.alias = ""
+	insert := InsertBuilder{
+		InsertBuilder: sq.Insert(t.Name),
+		hooks: []hook[sq.InsertBuilder]{
+			t.insertTimestamps,
+			t.insertTenant,
+		},
+	}
+
 	if t.IsPostgres() {
-		return InsertBuilder{psql.Insert(t.Name)}
+		insert.InsertBuilder = psql.Insert(t.Name)
 	}
 
-	return InsertBuilder{sq.Insert(t.Name)}
+	if t.Audit != nil {
+		insert.wrapRun = t.auditInsert
+	}
+
+	return insert
 }
 
 // Update returns a buildable Update statement that is bound to a specific table name
 func (t Table[T]) Update() UpdateBuilder {
+	t.alias = ""
+	update := UpdateBuilder{
+		UpdateBuilder: sq.Update(t.Name),
+		hooks: []hook[sq.UpdateBuilder]{
+			t.updateTimestamps,
+			t.updateTenant,
+		},
+	}
+
 	if t.IsPostgres() {
-		return UpdateBuilder{psql.Update(t.Name)}
+		update.UpdateBuilder = psql.Update(t.Name)
 	}
 
-	return UpdateBuilder{sq.Update(t.Name)}
+	for _, scope := range t.scopes() {
+		update.UpdateBuilder = update.UpdateBuilder.Where(scope)
+	}
+
+	if t.Audit != nil {
+		update.wrapRun = t.auditUpdate
+	}
+
+	return update
 }
 
-// Delete returns a buildable Delete statement that is bound to a specific table name
+// Delete returns a buildable Delete statement that is bound to a specific table name.
+// If the table has a SoftDeleteColumn, the rows are marked as deleted instead
 func (t Table[T]) Delete() DeleteBuilder {
+	t.alias = ""
+	delete := t.HardDelete()
+	if t.SoftDeleteColumn != "" {
+		delete.asUpdate = t.softDelete
+
+		if t.Audit != nil {
+			delete.wrapRun = t.auditSoftDelete
+		}
+	}
+
+	return delete
+}
+
+// HardDelete is like Delete but always removes the rows, even if the table has a
+// SoftDeleteColumn
+func (t Table[T]) HardDelete() DeleteBuilder {
+	t.alias = ""
+	delete := DeleteBuilder{
+		DeleteBuilder: sq.Delete(t.Name),
+		hooks:         []hook[sq.DeleteBuilder]{t.deleteTenant},
+	}
 	if t.IsPostgres() {
-		return DeleteBuilder{psql.Delete(t.Name)}
+		delete.DeleteBuilder = psql.Delete(t.Name)
+	}
+
+	for _, scope := range t.scopes() {
+		delete.DeleteBuilder = delete.DeleteBuilder.Where(scope)
+	}
+
+	if t.Audit != nil {
+		delete.wrapRun = t.auditDelete
 	}
 
-	return DeleteBuilder{sq.Delete(t.Name)}
+	return delete
 }
@@ -0,0 +1,118 @@
+package azamat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrNoTenant is returned when a query on a tenant-scoped table is run without a
+// tenant in its context
+var ErrNoTenant = errors.New("no tenant in context")
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of the context that carries the given tenant ID. Queries
+// on tenant-scoped tables that are run with this context only see that tenant's rows
+func WithTenant(ctx context.Context, tenantID any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID carried by the context, if any
+func TenantFromContext(ctx context.Context) (any, bool) {
+	tenantID := ctx.Value(tenantKey{})
+	return tenantID, tenantID != nil
+}
+
+// tenantColumn returns the TenantColumn qualified with the table name or alias
+func (t Table[T]) tenantColumn() string {
+	return fmt.Sprintf("%s.%s", t.ref(), t.TenantColumn)
+}
+
+// tenantScope returns the predicate that limits a statement to the context's tenant
+func (t Table[T]) tenantScope(ctx context.Context) (sq.Sqlizer, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return sq.Eq{t.tenantColumn(): tenantID}, nil
+}
+
+func (t Table[T]) selectTenant(
+	ctx context.Context, b sq.SelectBuilder,
+) (sq.SelectBuilder, error) {
+	if t.TenantColumn == "" {
+		return b, nil
+	}
+
+	scope, err := t.tenantScope(ctx)
+	if err != nil {
+		return b, err
+	}
+	return b.Where(scope), nil
+}
+
+// updateTenant limits an update to the context's tenant. Callers aren't allowed to
+// set the tenant column, since that would move rows into another tenant
+func (t Table[T]) updateTenant(
+	ctx context.Context, b sq.UpdateBuilder,
+) (sq.UpdateBuilder, error) {
+	if t.TenantColumn == "" {
+		return b, nil
+	}
+
+	if contains(updateColumns(b), t.TenantColumn) {
+		return b, fmt.Errorf("%s can't be changed", t.TenantColumn)
+	}
+
+	scope, err := t.tenantScope(ctx)
+	if err != nil {
+		return b, err
+	}
+	return b.Where(scope), nil
+}
+
+func (t Table[T]) deleteTenant(
+	ctx context.Context, b sq.DeleteBuilder,
+) (sq.DeleteBuilder, error) {
+	if t.TenantColumn == "" {
+		return b, nil
+	}
+
+	scope, err := t.tenantScope(ctx)
+	if err != nil {
+		return b, err
+	}
+	return b.Where(scope), nil
+}
+
+// insertTenant sets the tenant column of every row being inserted. Callers aren't
+// allowed to set the tenant column themselves, since that could write rows into
+// another tenant
+func (t Table[T]) insertTenant(
+	ctx context.Context, b sq.InsertBuilder,
+) (sq.InsertBuilder, error) {
+	if t.TenantColumn == "" {
+		return b, nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return b, ErrNoTenant
+	}
+
+	if contains(insertColumns(b), t.TenantColumn) {
+		return b, fmt.Errorf("%s is set automatically from the context", t.TenantColumn)
+	}
+
+	// The tenant can only be added to rows of Values, not to an INSERT ... SELECT
+	if len(insertValues(b)) == 0 {
+		return b, fmt.Errorf(
+			"inserts into %s need Values, so %s can be set", t.Name, t.TenantColumn,
+		)
+	}
+
+	return addInsertColumn(b, t.TenantColumn, tenantID), nil
+}
@@ -0,0 +1,136 @@
+package azamat
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableTenant(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID       int
+		Title    string
+		TenantID int `db:"tenant_id"`
+	}
+
+	TodoTable := Table[Todo]{
+		Name:         "todos",
+		Columns:      []string{"id", "title", "tenant_id"},
+		TenantColumn: "tenant_id",
+	}
+
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		tenant_id INTEGER NOT NULL
+	)`)
+
+	kazakhstan := WithTenant(context.Background(), 1)
+	america := WithTenant(context.Background(), 2)
+
+	// When inserting without a tenant...
+	insert := TodoTable.Insert().Columns("title").Values("assist Borat")
+	_, err := insert.Run(db)
+	require.Equal(t, ErrNoTenant, err)
+
+	// When inserting with a tenant...
+	_, err = insert.RunContext(kazakhstan, db)
+	require.NoError(t, err)
+
+	insert = TodoTable.Insert().Columns("title").Values("find Pamela")
+	_, err = insert.RunContext(america, db)
+	require.NoError(t, err)
+
+	// When trying to insert into a different tenant...
+	insert = TodoTable.
+		Insert().
+		Columns("title", "tenant_id").
+		Values("buy bear food", 2)
+
+	_, err = insert.RunContext(kazakhstan, db)
+	require.Error(t, err)
+
+	// When reading without a tenant...
+	_, err = TodoTable.GetAll(db)
+	require.Equal(t, ErrNoTenant, err)
+
+	_, err = TodoTable.BasicSelect().RunWith(db).Query()
+	require.Equal(t, ErrNoTenant, err)
+
+	// When reading with a tenant...
+	todos, err := TodoTable.GetAllContext(kazakhstan, db)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "assist Borat", todos[0].Title)
+	assert.Equal(t, 1, todos[0].TenantID)
+
+	_, err = TodoTable.GetByIDContext(kazakhstan, db, 2)
+	require.Error(t, err)
+
+	todos, err = TodoTable.GetByIDsContext(america, db, 1, 2)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "find Pamela", todos[0].Title)
+
+	// When inserting from a select, which the tenant can't be added to...
+	_, err = TodoTable.Insert().
+		Columns("title").
+		Select(TodoTable.BasicSelect("title")).
+		RunContext(kazakhstan, db)
+	require.EqualError(t, err, "inserts into todos need Values, so tenant_id can be set")
+
+	// When trying to move a row into a different tenant...
+	_, err = TodoTable.Update().Set("tenant_id", 2).RunContext(kazakhstan, db)
+	require.EqualError(t, err, "tenant_id can't be changed")
+
+	todos, err = TodoTable.GetAllContext(america, db)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+
+	// When updating...
+	update := TodoTable.Update().Set("title", "fuel van")
+	_, err = update.Run(db)
+	require.Equal(t, ErrNoTenant, err)
+
+	_, err = update.RunContext(kazakhstan, db)
+	require.NoError(t, err)
+
+	// Make sure only the tenant's rows were updated
+	todo, err := TodoTable.GetByIDContext(america, db, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "find Pamela", todo.Title)
+
+	// When deleting...
+	_, err = TodoTable.Delete().Run(db)
+	require.Equal(t, ErrNoTenant, err)
+
+	_, err = TodoTable.Delete().RunContext(america, db)
+	require.NoError(t, err)
+
+	// Make sure only the tenant's rows were deleted
+	todos, err = TodoTable.GetAllContext(kazakhstan, db)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	assert.Equal(t, "fuel van", todos[0].Title)
+
+	todos, err = TodoTable.GetAllContext(america, db)
+	require.NoError(t, err)
+	require.Len(t, todos, 0)
+
+	// When updating a row, its tenant isn't taken from the struct
+	todo, err = TodoTable.GetByIDContext(kazakhstan, db, 1)
+	require.NoError(t, err)
+
+	todo.TenantID = 2
+	err = TodoTable.UpdateRowContext(kazakhstan, db, &todo)
+	require.NoError(t, err)
+
+	todos, err = TodoTable.GetAllContext(kazakhstan, db)
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+}
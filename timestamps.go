@@ -0,0 +1,46 @@
+package azamat
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// now returns the current time according to the table's Clock
+func (t Table[T]) now() time.Time {
+	if t.Clock != nil {
+		return t.Clock()
+	}
+	return time.Now()
+}
+
+// insertTimestamps sets the created at and updated at columns for every row being
+// inserted, unless the caller already provided them
+func (t Table[T]) insertTimestamps(
+	_ context.Context, b sq.InsertBuilder,
+) (sq.InsertBuilder, error) {
+	if t.CreatedAtColumn == "" && t.UpdatedAtColumn == "" {
+		return b, nil
+	}
+
+	now := t.now()
+	for _, column := range []string{t.CreatedAtColumn, t.UpdatedAtColumn} {
+		if column != "" {
+			b = addInsertColumn(b, column, now)
+		}
+	}
+
+	return b, nil
+}
+
+// updateTimestamps sets the updated at column, unless the caller already set it
+func (t Table[T]) updateTimestamps(
+	_ context.Context, b sq.UpdateBuilder,
+) (sq.UpdateBuilder, error) {
+	if t.UpdatedAtColumn == "" || contains(updateColumns(b), t.UpdatedAtColumn) {
+		return b, nil
+	}
+
+	return b.Set(t.UpdatedAtColumn, t.now()), nil
+}
@@ -0,0 +1,89 @@
+package azamat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableTimestamps(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID        int
+		Title     string
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+
+	now := time.Date(2022, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	TodoTable := Table[Todo]{
+		Name:            "todos",
+		Columns:         []string{"id", "title", "created_at", "updated_at"},
+		CreatedAtColumn: "created_at",
+		UpdatedAtColumn: "updated_at",
+		Clock:           func() time.Time { return now },
+	}
+
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`)
+
+	// When inserting multiple entries...
+	todo1, todo2 := "assist Borat", "find Pamela"
+	insert := TodoTable.Insert().Columns("title").Values(todo1).Values(todo2)
+	_, err := insert.Run(db)
+	require.NoError(t, err)
+
+	// Make sure both timestamps were set on every entry
+	todos, err := TodoTable.GetAll(db)
+	require.NoError(t, err)
+	require.Len(t, todos, 2)
+	for _, todo := range todos {
+		assert.True(t, now.Equal(todo.CreatedAt))
+		assert.True(t, now.Equal(todo.UpdatedAt))
+	}
+
+	// When the caller sets a timestamp explicitly...
+	earlier := now.Add(-time.Hour)
+	insert = TodoTable.
+		Insert().
+		Columns("title", "created_at").
+		Values("buy bear food", earlier)
+
+	_, err = insert.Run(db)
+	require.NoError(t, err)
+
+	// Make sure the explicit value wins
+	todo, err := TodoTable.GetByID(db, 3)
+	require.NoError(t, err)
+	assert.True(t, earlier.Equal(todo.CreatedAt))
+	assert.True(t, now.Equal(todo.UpdatedAt))
+
+	// When updating an entry...
+	later := now.Add(time.Hour)
+	TodoTable.Clock = func() time.Time { return later }
+
+	update := TodoTable.Update().Set("title", "fuel van").Where("id = ?", 1)
+	_, err = update.Run(db)
+	require.NoError(t, err)
+
+	// Make sure only updated_at changed
+	todo, err = TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(todo.CreatedAt))
+	assert.True(t, later.Equal(todo.UpdatedAt))
+
+	// Make sure the timestamp is part of the generated SQL
+	sql, args, err := TodoTable.Update().Set("title", "x").ToSql()
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE todos SET title = ?, updated_at = ?", sql)
+	assert.Equal(t, []interface{}{"x", later}, args)
+}
@@ -24,3 +24,13 @@ func CommitTransaction(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// inTransaction runs fn in a transaction, unless the runner already is one
+func inTransaction(runner Runner, fn func(Runner) error) error {
+	if db, ok := runner.(*sqlx.DB); ok {
+		return CommitTransaction(db, func(tx *sqlx.Tx) error {
+			return fn(tx)
+		})
+	}
+	return fn(runner)
+}
@@ -1,6 +1,7 @@
 package azamat
 
 import (
+	"context"
 	"database/sql"
 
 	sq "github.com/Masterminds/squirrel"
@@ -8,14 +9,97 @@ import (
 
 type UpdateBuilder struct {
 	sq.UpdateBuilder
+	hooks   []hook[sq.UpdateBuilder]
+	wrapRun wrapRun[sq.UpdateBuilder]
 }
 
 func Update(table string) UpdateBuilder {
-	return UpdateBuilder{sq.Update(table)}
+	return UpdateBuilder{UpdateBuilder: sq.Update(table)}
 }
 
 func (b UpdateBuilder) Run(runner Runner) (sql.Result, error) {
-	return b.RunWith(runner).Exec()
+	return b.RunContext(context.Background(), runner)
+}
+
+// RunContext is like Run but passes the context to the database and to any behavior
+// that the statement's Table adds (eg: tenant isolation)
+func (b UpdateBuilder) RunContext(
+	ctx context.Context, runner Runner,
+) (sql.Result, error) {
+	if b.wrapRun == nil {
+		return b.RunWith(withContext(runner)).ExecContext(ctx)
+	}
+
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exec := func(runner Runner) (sql.Result, error) {
+		return built.RunWith(withContext(runner)).ExecContext(ctx)
+	}
+	return b.wrapRun(ctx, runner, built, exec)
+}
+
+func (b UpdateBuilder) build(ctx context.Context) (sq.UpdateBuilder, error) {
+	return applyHooks(ctx, b.UpdateBuilder, b.hooks)
+}
+
+// ToSql builds the query into a SQL string and bound args
+func (b UpdateBuilder) ToSql() (string, []interface{}, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return "", nil, err
+	}
+	return built.ToSql()
+}
+
+func (b UpdateBuilder) Exec() (sql.Result, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return built.Exec()
+}
+
+func (b UpdateBuilder) Query() (*sql.Rows, error) {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return built.Query()
+}
+
+func (b UpdateBuilder) QueryRow() sq.RowScanner {
+	built, err := b.build(context.Background())
+	if err != nil {
+		return errRow{err}
+	}
+	return built.QueryRow()
+}
+
+func (b UpdateBuilder) ExecContext(ctx context.Context) (sql.Result, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.ExecContext(ctx)
+}
+
+func (b UpdateBuilder) QueryContext(ctx context.Context) (*sql.Rows, error) {
+	built, err := b.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return built.QueryContext(ctx)
+}
+
+func (b UpdateBuilder) QueryRowContext(ctx context.Context) sq.RowScanner {
+	built, err := b.build(ctx)
+	if err != nil {
+		return errRow{err}
+	}
+	return built.QueryRowContext(ctx)
 }
 
 func (b UpdateBuilder) PlaceholderFormat(f sq.PlaceholderFormat) UpdateBuilder {
@@ -0,0 +1,68 @@
+package azamat
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ErrStaleObject is returned by UpdateRow when the row has been changed (or deleted)
+// since it was read, ie: its version no longer matches
+type ErrStaleObject struct {
+	Table   string
+	ID      any
+	Version int64
+}
+
+func (e ErrStaleObject) Error() string {
+	return fmt.Sprintf(
+		"%s %v is stale: it no longer has version %d", e.Table, e.ID, e.Version,
+	)
+}
+
+// updateVersioned runs an update that only succeeds if the row still has the version
+// that the struct has. On success, the struct's version is incremented to match
+func (t Table[T]) updateVersioned(
+	ctx context.Context,
+	runner Runner,
+	update UpdateBuilder,
+	row reflect.Value,
+	id any,
+) error {
+	field, err := readRowField(row, t.VersionColumn)
+	if err != nil {
+		return err
+	}
+
+	if !field.CanInt() {
+		return fmt.Errorf("%s must be an integer", t.VersionColumn)
+	}
+	version := field.Int()
+
+	result, err := update.
+		Set(t.VersionColumn, version+1).
+		Where(sq.Eq{t.VersionColumn: version}).
+		RunContext(ctx, runner)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrStaleObject{Table: t.Name, ID: id, Version: version}
+	}
+
+	// Only now is the struct changed, which may allocate a nil embedded struct
+	field, err = rowField(row, t.VersionColumn)
+	if err != nil {
+		return err
+	}
+	field.SetInt(version + 1)
+	return nil
+}
@@ -0,0 +1,68 @@
+package azamat
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableUpdateRowVersion(t *testing.T) {
+	db, _ := sqlx.Open("sqlite3", ":memory:")
+
+	type Todo struct {
+		ID      int
+		Title   string
+		Version int
+	}
+
+	TodoTable := Table[Todo]{
+		Name:          "todos",
+		Columns:       []string{"id", "title", "version"},
+		VersionColumn: "version",
+	}
+
+	db.MustExec(`CREATE TABLE todos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1
+	)`)
+
+	db.MustExec(`INSERT INTO todos (title) VALUES (?)`, "assist Borat")
+
+	// Two admins read the same todo...
+	borat, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+
+	azamat, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+
+	// When the first one saves...
+	borat.Title = "find Pamela"
+	err = TodoTable.UpdateRow(db, &borat)
+	require.NoError(t, err)
+	assert.Equal(t, 2, borat.Version)
+
+	// When the second one saves...
+	azamat.Title = "buy bear food"
+	err = TodoTable.UpdateRow(db, &azamat)
+	require.Equal(t, ErrStaleObject{Table: "todos", ID: 1, Version: 1}, err)
+	assert.Equal(t, 1, azamat.Version)
+
+	// Make sure the first save wasn't overwritten
+	todo, err := TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "find Pamela", todo.Title)
+	assert.Equal(t, 2, todo.Version)
+
+	// When saving again with the latest version...
+	todo.Title = "fuel van"
+	err = TodoTable.UpdateRow(db, &todo)
+	require.NoError(t, err)
+
+	todo, err = TodoTable.GetByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "fuel van", todo.Title)
+	assert.Equal(t, 3, todo.Version)
+}
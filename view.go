@@ -18,62 +18,38 @@ type View[T any] struct {
 
 	// Query is the custom query that will be used to fetch the entity
 	Query func() sq.SelectBuilder
-}
 
-func (v View[T]) GetAll(runner Runner) ([]T, error) {
-	sql, args, err := v.Query().ToSql()
-	if err != nil {
-		return nil, err
-	}
+	// DefaultScopes are predicates that are applied to every query of the view. Use
+	// Unscoped to opt out of them
+	DefaultScopes []sq.Sqlizer
 
-	var rows []T
-	err = runner.Select(&rows, sql, args...)
-	return rows, err
+	// unscoped is set when DefaultScopes should not be applied
+	unscoped bool
 }
 
-func (v View[T]) GetByID(runner Runner, id int) (T, error) {
-	var row T
-
-	idColumn := "id"
-	idFrom := v.IDFrom.String()
-	if idFrom != "" {
-		idColumn = fmt.Sprintf("%s.id", idFrom)
-	}
-
-	sql, args, err := v.Query().Where(sq.Eq{idColumn: id}).ToSql()
-	if err != nil {
-		return row, err
-	}
-
-	var rows []T
-	if err := runner.Select(&rows, sql, args...); err != nil {
-		return row, err
-	}
-
-	if len(rows) == 0 {
-		return row, fmt.Errorf("none found")
-	}
+// Select returns the view's query as a buildable Select query, so it can be narrowed
+// down further
+func (v View[T]) Select() SelectBuilder[T] {
+	return SelectBuilder[T]{SelectBuilder: v.scoped(v.Query())}
+}
 
-	if len(rows) != 1 {
-		return row, fmt.Errorf("expected to only get row")
-	}
+func (v View[T]) GetAll(runner Runner) ([]T, error) {
+	return v.Select().All(runner)
+}
 
-	return rows[0], nil
+func (v View[T]) GetByID(runner Runner, id int) (T, error) {
+	return v.Select().Where(sq.Eq{v.idColumn(): id}).Only(runner)
 }
 
 func (v View[T]) GetByIDs(runner Runner, ids ...int) ([]T, error) {
+	return v.Select().Where(sq.Eq{v.idColumn(): ids}).All(runner)
+}
+
+func (v View[T]) idColumn() string {
 	idColumn := "id"
 	idFrom := v.IDFrom.String()
 	if idFrom != "" {
 		idColumn = fmt.Sprintf("%s.id", idFrom)
 	}
-
-	sql, args, err := v.Query().Where(sq.Eq{idColumn: ids}).ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	var rows []T
-	err = runner.Select(&rows, sql, args...)
-	return rows, err
+	return idColumn
 }